@@ -6,6 +6,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"runtime"
 
 	"github.com/reekoheek/gopas/util"
 
@@ -17,9 +18,10 @@ import (
  */
 func main() {
 	var (
-		tool *util.Tool
-		cwd  string
-		err  error
+		tool    *util.Tool
+		project *util.ProjectImpl
+		cwd     string
+		err     error
 	)
 
 	if cwd, err = os.Getwd(); err != nil {
@@ -27,7 +29,8 @@ func main() {
 	}
 
 	logger := util.NewLogger(os.Stdout, os.Stderr)
-	if tool, err = util.NewTool(logger, util.NewProject(logger, cwd)); err != nil {
+	project = util.NewProject(logger, cwd)
+	if tool, err = util.NewTool(logger, project); err != nil {
 		panic(err.Error())
 	}
 
@@ -35,12 +38,38 @@ func main() {
 		Name:    "gopas",
 		Usage:   "Go build tool outside GOPATH",
 		Version: "0.1.0",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "offline",
+				Usage: "use only repositories from download cache",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			project.Cache.Offline = c.Bool("offline")
+			return nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "build",
 				Aliases: []string{"b"},
 				Usage:   "build project",
 				Action:  tool.DoBuild,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "frozen",
+						Usage: "fail when gopas.lock does not match dependencies",
+					},
+					&cli.BoolFlag{
+						Name:  "keep-going",
+						Usage: "go on when some dependencies fail to be fetched or restored",
+					},
+					&cli.IntFlag{
+						Name:    "jobs",
+						Aliases: []string{"j"},
+						Value:   runtime.NumCPU(),
+						Usage:   "fetch and install this many dependencies in parallel",
+					},
+				},
 			},
 			{
 				Name:    "clean",
@@ -53,18 +82,171 @@ func main() {
 				Aliases: []string{"i"},
 				Usage:   "install dependencies",
 				Action:  tool.DoInstall,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "frozen",
+						Usage: "fail when gopas.lock does not match dependencies",
+					},
+					&cli.BoolFlag{
+						Name:  "keep-going",
+						Usage: "go on when some dependencies fail to be fetched or restored",
+					},
+					&cli.IntFlag{
+						Name:    "jobs",
+						Aliases: []string{"j"},
+						Value:   runtime.NumCPU(),
+						Usage:   "fetch and install this many dependencies in parallel",
+					},
+				},
+			},
+			{
+				Name:      "import",
+				Usage:     "generate gopas.yml from Godeps, glide, dep, govendor or go.mod manifest",
+				ArgsUsage: "[godep|glide|dep|govendor|gomod]",
+				Action:    tool.DoImport,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "overwrite existing gopas.yml",
+					},
+				},
+			},
+			{
+				Name:      "export",
+				Usage:     "generate go.mod and go.sum from gopas.yml and gopas.lock",
+				ArgsUsage: "gomod",
+				Action:    tool.DoExport,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "overwrite existing go.mod",
+					},
+				},
+			},
+			{
+				Name:    "outdated",
+				Aliases: []string{"o"},
+				Usage:   "report dependencies behind their version or newest tag",
+				Action:  tool.DoOutdated,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print report as json",
+					},
+				},
+			},
+			{
+				Name:      "add",
+				Aliases:   []string{"a"},
+				Usage:     "add dependencies",
+				ArgsUsage: "<package>[=<version>]...",
+				Action:    tool.DoAdd,
+			},
+			{
+				Name:      "remove",
+				Aliases:   []string{"rm"},
+				Usage:     "remove dependencies",
+				ArgsUsage: "<package>...",
+				Action:    tool.DoRemove,
+			},
+			{
+				Name:    "update",
+				Aliases: []string{"u"},
+				Usage:   "update dependencies within their version",
+				Action:  tool.DoUpdate,
+			},
+			{
+				Name:      "search",
+				Aliases:   []string{"s"},
+				Usage:     "search packages seen in vendor dirs of every project",
+				ArgsUsage: "<query>",
+				Action:    tool.DoSearch,
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "limit",
+						Value: 20,
+						Usage: "show at most this many results, 0 for all",
+					},
+				},
 			},
-			//{
-			//	Name:    "search",
-			//	Aliases: []string{"s"},
-			//	Usage:   "search from cache",
-			//	Action:  tool.DoSearch,
-			//},
 			{
 				Name:    "list",
 				Aliases: []string{"l"},
 				Usage:   "list dependencies",
 				Action:  tool.DoList,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "tree",
+						Usage: "print import tree of direct dependencies",
+					},
+				},
+			},
+			{
+				Name:   "audit",
+				Usage:  "match vendored packages against OSV advisory database",
+				Action: tool.DoAudit,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "db",
+						Usage: "advisory database, OSV json file or dir of them",
+					},
+					&cli.StringFlag{
+						Name:  "fail-on",
+						Value: "low",
+						Usage: "fail on advisories of this severity or above: low, moderate, high or critical",
+					},
+				},
+			},
+			{
+				Name:   "licenses",
+				Usage:  "list licenses of vendored packages",
+				Action: tool.DoLicenses,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "csv",
+						Usage: "print list as csv",
+					},
+				},
+			},
+			{
+				Name:  "deps",
+				Usage: "inspect dependencies",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "check",
+						Usage:  "report unused and undeclared dependencies",
+						Action: tool.DoDepsCheck,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "fix",
+								Usage: "update gopas.yml to match imports",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:  "vendor",
+				Usage: "manage vendored packages",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "prune",
+						Usage:  "remove files of vendored packages the import graph does not need",
+						Action: tool.DoVendorPrune,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "list files that would be removed",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:      "why",
+				Usage:     "print shortest import chain from project to package",
+				ArgsUsage: "<package>",
+				Action:    tool.DoWhy,
 			},
 			{
 				Name:    "run",
@@ -108,7 +290,7 @@ func main() {
 					&cli.StringSliceFlag{
 						Name:    "ignore",
 						Aliases: []string{"i"},
-						Value:   cli.NewStringSlice(".git", ".gopath"),
+						Usage:   "glob of paths not watched, besides " + util.GOPASIGNORE,
 					},
 					&cli.StringFlag{
 						Name:    "exec",
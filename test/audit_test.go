@@ -0,0 +1,104 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_AUDIT_DIR = ".tmp/audit"
+)
+
+func Test_Audit_Match(t *testing.T) {
+	test_gomod_SetUp(t)
+	defer test_gomod_TearDown()
+	defer os.RemoveAll(TEST_AUDIT_DIR)
+
+	bar := filepath.Join(TEST_GOMOD_DIR, "src/example.com/bar")
+	fixed := test_vcs_Git(t, bar, "rev-parse", "master")
+
+	os.RemoveAll(TEST_AUDIT_DIR)
+	os.MkdirAll(filepath.Join(TEST_AUDIT_DIR, "go"), 0755)
+	ioutil.WriteFile(filepath.Join(TEST_AUDIT_DIR, "GHSA-0001.json"), []byte(`{
+	"id": "GHSA-0001",
+	"summary": "foo overflows",
+	"affected": [{
+		"package": {"ecosystem": "Go", "name": "example.com/foo"},
+		"ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.0.1"}]}]
+	}],
+	"database_specific": {"severity": "HIGH"}
+}`), 0644)
+	ioutil.WriteFile(filepath.Join(TEST_AUDIT_DIR, "go", "all.json"), []byte(`[{
+	"id": "GO-0002",
+	"affected": [{
+		"package": {"name": "example.com/foo/sub"},
+		"ranges": [{"type": "SEMVER", "events": [{"introduced": "1.1.0"}]}]
+	}]
+}, {
+	"id": "GO-0003",
+	"summary": "bar leaks",
+	"affected": [{
+		"package": {"ecosystem": "Go", "name": "example.com/bar"},
+		"ranges": [{"type": "GIT", "events": [{"introduced": "0"}, {"fixed": "`+fixed+`"}]}]
+	}]
+}, {
+	"id": "GO-0004",
+	"withdrawn": "2020-01-01T00:00:00Z",
+	"affected": [{"package": {"name": "example.com/foo"}, "versions": ["v1.0.0"]}]
+}, {
+	"id": "NPM-0005",
+	"affected": [{"package": {"ecosystem": "npm", "name": "example.com/foo"}, "versions": ["v1.0.0"]}]
+}]`), 0644)
+	ioutil.WriteFile(filepath.Join(TEST_AUDIT_DIR, "README.md"), []byte("not an advisory"), 0644)
+
+	db, err := util.LoadAdvisories(TEST_AUDIT_DIR)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(db.Advisories) != 4 {
+		t.Errorf("Wrong advisories loaded %d", len(db.Advisories))
+		return
+	}
+
+	vendorDir := filepath.Join(TEST_GOMOD_DIR, "src")
+	lock, _ := util.NewLock(vendorDir, []util.Dependency{})
+	findings, unversioned, err := db.Audit(vendorDir, lock)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(unversioned) != 0 || len(findings) != 2 {
+		t.Errorf("Wrong findings %v %v", findings, unversioned)
+		return
+	}
+	if f := findings[0]; f.Name != "example.com/bar" || f.ID != "GO-0003" || f.Version != "v1.0.0" || f.Severity != "unknown" {
+		t.Errorf("Wrong finding %v", *f)
+	}
+	if f := findings[1]; f.Name != "example.com/foo" || f.ID != "GHSA-0001" || f.Severity != "high" || len(f.Fixed) != 1 || f.Fixed[0] != "1.0.1" {
+		t.Errorf("Wrong finding %v", *f)
+	}
+
+	test_vcs_Git(t, bar, "checkout", "-q", "master")
+	lock, _ = util.NewLock(vendorDir, []util.Dependency{})
+	findings, unversioned, _ = db.Audit(vendorDir, lock)
+	if len(findings) != 1 || len(unversioned) != 1 || unversioned[0] != "example.com/bar" {
+		t.Errorf("Fixed revision must not be affected %v %v", findings, unversioned)
+	}
+}
+
+func Test_Audit_Severity(t *testing.T) {
+	if util.SeverityLevel("MEDIUM") != util.SeverityLevel("moderate") {
+		t.Error("Medium must be moderate")
+	}
+	if util.SeverityLevel("unknown") <= util.SeverityLevel("critical") {
+		t.Error("Unknown must rank above critical")
+	}
+	if _, err := util.ParseSeverity("severe"); err == nil {
+		t.Error("Must refuse unknown severity")
+	}
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+func Test_Cache_StoreAndClone(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	vendorDir := filepath.Join(TEST_VCS_DIR, "src")
+	dir := filepath.Join(vendorDir, "example.com/foo")
+	origin, _ := util.VcsGit.Remote(dir)
+
+	cache := util.NewCache(filepath.Join(TEST_VCS_DIR, "cache"), false)
+	if err := cache.StoreAll(vendorDir); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if repo, ok := cache.Lookup("example.com/foo/sub"); !ok || repo != "example.com/foo" {
+		t.Errorf("Wrong cached repository %s", repo)
+		return
+	}
+
+	os.RemoveAll(dir)
+	if err := cache.Clone("example.com/foo", dir); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if remote, _ := util.VcsGit.Remote(dir); remote != origin {
+		t.Errorf("Remote %s does not point to origin %s", remote, origin)
+	}
+
+	if err := util.VcsGit.Checkout(dir, "v1.0.0"); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func Test_Cache_MirrorOfOtherRemote(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	vendorDir := filepath.Join(TEST_VCS_DIR, "src")
+	origin, _ := util.VcsGit.Remote(filepath.Join(vendorDir, "example.com/foo"))
+	cache := util.NewCache(filepath.Join(TEST_VCS_DIR, "cache"), false)
+	if err := cache.StoreAll(vendorDir); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	fork, _ := filepath.Abs(filepath.Join(TEST_VCS_DIR, "fork.git"))
+	test_project_BareRepo(t, fork, map[string]string{"fork.go": "package foo\n"})
+
+	if !cache.Mirrors("example.com/foo", util.VcsGit, origin) {
+		t.Error("Cache must mirror origin")
+	}
+	if cache.Mirrors("example.com/foo", util.VcsGit, fork) || cache.Mirrors("example.com/foo", util.VcsHg, origin) {
+		t.Error("Cache must not mirror other remote or vcs")
+	}
+
+	dest := filepath.Join(TEST_VCS_DIR, "other/example.com/foo")
+	if err := cache.Create("example.com/foo", util.VcsGit, fork, dest); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if remote, _ := util.VcsGit.Remote(dest); remote != fork {
+		t.Errorf("Remote %s does not point to fork %s", remote, fork)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "fork.go")); err != nil {
+		t.Error("Checkout must come from fork, not cached mirror of origin")
+	}
+
+	offline := util.NewCache(cache.Dir, true)
+	if err := offline.Create("example.com/foo", util.VcsGit, fork, filepath.Join(TEST_VCS_DIR, "offline")); err == nil {
+		t.Error("Must fail offline when mirror is of other remote")
+	}
+}
+
+func Test_Cache_Offline(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	cache := util.NewCache(filepath.Join(TEST_VCS_DIR, "cache"), true)
+	dest := filepath.Join(TEST_VCS_DIR, "src/example.com/bar")
+	if err := cache.Create("example.com/bar", util.VcsGit, "https://example.com/bar", dest); err == nil {
+		t.Error("Must fail creating uncached repository offline")
+	}
+}
+
+func Test_Cache_OfflineCheckout(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	dir := filepath.Join(TEST_VCS_DIR, "src/example.com/foo")
+	origin := filepath.Join(TEST_VCS_DIR, "origin")
+	ioutil.WriteFile(filepath.Join(origin, "sub", "sub.go"), []byte("package sub\n\n// third\n"), 0644)
+	test_vcs_Git(t, origin, "commit", "-q", "-a", "-m", "third")
+	head := test_vcs_Git(t, origin, "rev-parse", "HEAD")
+
+	cache := util.NewCache(filepath.Join(TEST_VCS_DIR, "cache"), true)
+	if err := cache.Checkout(util.VcsGit, "example.com/foo", dir, "v1.0.0"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	err := cache.Checkout(util.VcsGit, "example.com/foo", dir, head)
+	if err == nil || !strings.Contains(err.Error(), "can not be downloaded offline") {
+		t.Errorf("Wrong error %v", err)
+	}
+	if err = cache.CheckoutUpstream(util.VcsGit, "example.com/foo", dir, head); err == nil {
+		t.Error("Must fail checking out upstream of unknown revision offline")
+	}
+
+	if err = exec.Command("git", "-C", dir, "cat-file", "-e", head).Run(); err == nil {
+		t.Error("Offline checkout must not download from remote")
+	}
+}
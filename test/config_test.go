@@ -0,0 +1,85 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_CONFIG_DIR = ".tmp/config"
+)
+
+func Test_Config_Rewrite(t *testing.T) {
+	os.RemoveAll(TEST_CONFIG_DIR)
+	os.MkdirAll(TEST_CONFIG_DIR, 0755)
+	defer os.RemoveAll(TEST_CONFIG_DIR)
+
+	file := filepath.Join(TEST_CONFIG_DIR, ".gopas.yml")
+	ioutil.WriteFile(file, []byte(`rewrites:
+  - golang.org/x/* => git@mirror.internal:golang/*
+  - example.com/*/* => hg+file:///repos/*-*
+  - example.com/pinned => /repos/pinned.git
+`), 0644)
+
+	config, err := util.ReadConfig(file)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	repo, vcs, remote, ok := config.Rewrite("golang.org/x/net/context")
+	if !ok || repo != "golang.org/x/net" || vcs != util.VcsGit || remote != "git@mirror.internal:golang/net" {
+		t.Errorf("Wrong rewrite %s %v %s", repo, vcs, remote)
+	}
+
+	repo, vcs, remote, ok = config.Rewrite("example.com/foo/bar/baz")
+	if !ok || repo != "example.com/foo/bar" || vcs != util.VcsHg || remote != "file:///repos/foo-bar" {
+		t.Errorf("Wrong rewrite %s %v %s", repo, vcs, remote)
+	}
+
+	if _, _, _, ok = config.Rewrite("golang.org/x"); ok {
+		t.Error("Must not rewrite path shorter than pattern")
+	}
+	if _, _, _, ok = config.Rewrite("github.com/foo/bar"); ok {
+		t.Error("Must not rewrite unmatched path")
+	}
+
+	if _, err = util.ParseRewrite("example.com/foo => /repos/*"); err == nil {
+		t.Error("Must refuse remote using unmatched *")
+	}
+}
+
+func Test_Config_Missing(t *testing.T) {
+	config, err := util.ReadConfig(filepath.Join(TEST_CONFIG_DIR, "missing.yml"))
+	if err != nil || len(config.Rewrites) != 0 {
+		t.Error("Missing config must be empty")
+	}
+}
+
+func Test_Config_Advisories(t *testing.T) {
+	os.RemoveAll(TEST_CONFIG_DIR)
+	os.MkdirAll(TEST_CONFIG_DIR, 0755)
+	defer os.RemoveAll(TEST_CONFIG_DIR)
+
+	file := filepath.Join(TEST_CONFIG_DIR, ".gopas.yml")
+	ioutil.WriteFile(file, []byte("advisories: osv\n"), 0644)
+
+	config, err := util.ReadConfig(file)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if config.AdvisoriesPath() != filepath.Join(TEST_CONFIG_DIR, "osv") {
+		t.Errorf("Wrong advisories %s", config.AdvisoriesPath())
+	}
+
+	os.Setenv("GOPAS_ADVISORIES", "/osv/all.json")
+	defer os.Unsetenv("GOPAS_ADVISORIES")
+	if config.AdvisoriesPath() != "/osv/all.json" {
+		t.Errorf("Wrong advisories %s", config.AdvisoriesPath())
+	}
+}
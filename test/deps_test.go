@@ -0,0 +1,45 @@
+package test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+func Test_Deps_CheckDependencies(t *testing.T) {
+	test_graph_SetUp()
+	defer test_graph_TearDown()
+
+	vendorDir := filepath.Join(TEST_GRAPH_DIR, "vendor")
+	graph, err := util.NewFullImportGraph("example.com/app", filepath.Join(TEST_GRAPH_DIR, "project"), vendorDir)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	report := util.CheckDependencies(graph, []util.Dependency{
+		{Name: "example.com/a", Version: "v1.0.0"},
+		{Name: "example.com/c"},
+		{Name: "example.com/unused"},
+		{Name: "example.com/gone"},
+	}, vendorDir)
+
+	if report.IsClean() {
+		t.Error("Report must not be clean")
+	}
+
+	// example.com/unused is imported by tests of project only
+	if len(report.Unused) != 1 || report.Unused[0].Name != "example.com/gone" {
+		t.Errorf("Wrong unused %v", report.Unused)
+	}
+
+	if !reflect.DeepEqual(report.Undeclared, []string{"example.com/b"}) {
+		t.Errorf("Wrong undeclared %v", report.Undeclared)
+	}
+
+	if len(report.Missing) != 0 {
+		t.Errorf("Wrong missing %v", report.Missing)
+	}
+}
@@ -0,0 +1,166 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_GOMOD_DIR = ".tmp/gomod"
+)
+
+func Test_GoMod_Export(t *testing.T) {
+	test_gomod_SetUp(t)
+	defer test_gomod_TearDown()
+
+	vendorDir := filepath.Join(TEST_GOMOD_DIR, "src")
+	dependencies := []util.Dependency{
+		{Name: "example.com/foo/sub"},
+		{Name: "example.com/local", Path: "../local"},
+	}
+
+	mod := test_gomod_Export(t, vendorDir, dependencies)
+	if mod == nil {
+		return
+	}
+	test_gomod_AssertFile(t, "go.mod", `module example.com/app
+
+go 1.21.3
+
+require (
+	example.com/bar v1.0.0 // indirect
+	example.com/foo v1.0.0
+	example.com/local v0.0.0-00010101000000-000000000000
+)
+
+replace example.com/local => ../local
+`)
+
+	read, err := util.ReadGoMod(TEST_GOMOD_DIR)
+	if err != nil || read == nil || read.Go != "1.21.3" || len(read.Requires) != 3 || len(read.Replaces) != 1 || !read.Requires[0].Indirect {
+		t.Errorf("Wrong go.mod read %v %v", read, err)
+	}
+
+	bar := filepath.Join(vendorDir, "example.com/bar")
+	test_vcs_Git(t, bar, "checkout", "-q", "master")
+	seconds, _ := strconv.ParseInt(test_vcs_Git(t, bar, "show", "-s", "--format=%ct", "HEAD"), 10, 64)
+	revision := test_vcs_Git(t, bar, "rev-parse", "HEAD")
+
+	if mod = test_gomod_Export(t, vendorDir, dependencies); mod == nil {
+		return
+	}
+	pseudo := "v0.0.0-" + time.Unix(seconds, 0).UTC().Format("20060102150405") + "-" + revision[:12]
+	if mod.Requires[0].Version != pseudo {
+		t.Errorf("Wrong pseudo-version %s, expected %s", mod.Requires[0].Version, pseudo)
+	}
+}
+
+func Test_GoMod_Sum(t *testing.T) {
+	test_gomod_SetUp(t)
+	defer test_gomod_TearDown()
+
+	vendorDir := filepath.Join(TEST_GOMOD_DIR, "src")
+	lock, err := util.NewLock(vendorDir, []util.Dependency{})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	sum, err := util.NewSum(vendorDir, lock)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// expected hashes reported by go mod download for the same module
+	lines, err := util.GoSumLines(vendorDir, lock, sum)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected := []string{
+		"example.com/foo v1.0.0 h1:wGtDbnNxPblG098ALrF5ugxlOBGeifVCYjmNPDPOjLU=",
+		"example.com/foo v1.0.0/go.mod h1:tJ2YS1a8pyA3nrypRdbsq6Ias2I/0YUVbjNBUoLstcw=",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Wrong go.sum lines\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func test_gomod_SetUp(t *testing.T) {
+	os.RemoveAll(TEST_GOMOD_DIR)
+
+	foo := filepath.Join(TEST_GOMOD_DIR, "src/example.com/foo")
+	os.MkdirAll(filepath.Join(foo, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(foo, "go.mod"), []byte("module example.com/foo\n"), 0644)
+	ioutil.WriteFile(filepath.Join(foo, "sub", "sub.go"), []byte("package sub\n"), 0644)
+	ioutil.WriteFile(filepath.Join(foo, util.PROXYMETA), []byte("path: example.com/foo\nversion: v1.0.0\nproxy: file:///proxy\n"), 0644)
+
+	origin := filepath.Join(TEST_GOMOD_DIR, "origin")
+	os.MkdirAll(origin, 0755)
+	ioutil.WriteFile(filepath.Join(origin, "bar.go"), []byte("package bar\n"), 0644)
+	test_vcs_Git(t, origin, "init", "-q")
+	test_vcs_Git(t, origin, "checkout", "-q", "-b", "master")
+	test_vcs_Git(t, origin, "add", ".")
+	test_vcs_Git(t, origin, "commit", "-q", "-m", "first")
+	test_vcs_Git(t, origin, "tag", "v1.0.0")
+	ioutil.WriteFile(filepath.Join(origin, "bar.go"), []byte("package bar\n\n// second\n"), 0644)
+	test_vcs_Git(t, origin, "commit", "-q", "-a", "-m", "second")
+
+	abs, _ := filepath.Abs(origin)
+	test_vcs_Git(t, TEST_GOMOD_DIR, "clone", "-q", abs, "src/example.com/bar")
+	test_vcs_Git(t, filepath.Join(TEST_GOMOD_DIR, "src/example.com/bar"), "checkout", "-q", "v1.0.0")
+}
+
+func test_gomod_TearDown() {
+	os.RemoveAll(TEST_GOMOD_DIR)
+}
+
+func test_gomod_Export(t *testing.T, vendorDir string, dependencies []util.Dependency) *util.GoMod {
+	lock, err := util.NewLock(vendorDir, dependencies)
+	if err != nil {
+		t.Error(err.Error())
+		return nil
+	}
+
+	mod, err := util.NewGoMod(vendorDir, "example.com/app", dependencies, lock)
+	if err != nil {
+		t.Error(err.Error())
+		return nil
+	}
+	mod.Go = "1.21.3"
+	if err = mod.Write(TEST_GOMOD_DIR); err != nil {
+		t.Error(err.Error())
+		return nil
+	}
+	return mod
+}
+
+func Test_GoMod_DirectiveVersion(t *testing.T) {
+	for version, expected := range map[string]string{
+		"go1.21.3":             "1.21.3",
+		"go1.22rc1":            "1.22rc1",
+		"go1.20.5":             "1.20",
+		"go1.16":               "1.16",
+		"go1.19beta1":          "1.19",
+		"devel go1.23-abcdef0": "",
+		"":                     "",
+	} {
+		if directive := util.GoDirectiveVersion(version); directive != expected {
+			t.Errorf("Wrong directive version %q of %s, expected %q", directive, version, expected)
+		}
+	}
+}
+
+func test_gomod_AssertFile(t *testing.T, file string, expected string) {
+	content, _ := ioutil.ReadFile(filepath.Join(TEST_GOMOD_DIR, file))
+	if string(content) != expected {
+		t.Errorf("Wrong %s:\n%s", file, content)
+	}
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_GRAPH_DIR = ".tmp/graph"
+)
+
+func Test_Graph_Imports(t *testing.T) {
+	test_graph_SetUp()
+	defer test_graph_TearDown()
+
+	graph, err := util.NewImportGraph("example.com/app",
+		filepath.Join(TEST_GRAPH_DIR, "project"), filepath.Join(TEST_GRAPH_DIR, "vendor"))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if roots := graph.Roots(); !reflect.DeepEqual(roots, []string{"example.com/app", "example.com/app/sub"}) {
+		t.Errorf("Wrong roots %v", roots)
+	}
+
+	if direct := graph.Direct(); !reflect.DeepEqual(direct, []string{"example.com/a", "example.com/b"}) {
+		t.Errorf("Wrong direct imports %v", direct)
+	}
+
+	if reachable := graph.Reachable(); !reflect.DeepEqual(reachable, []string{"example.com/a", "example.com/b", "example.com/c", "example.com/missing"}) {
+		t.Errorf("Wrong reachable imports %v", reachable)
+	}
+
+	if chain := graph.Why("example.com/c"); !reflect.DeepEqual(chain, []string{"example.com/app", "example.com/a", "example.com/c"}) {
+		t.Errorf("Wrong chain %v", chain)
+	}
+
+	if chain := graph.Why("example.com/unused"); chain != nil {
+		t.Errorf("Unused package must have no chain %v", chain)
+	}
+}
+
+func test_graph_SetUp() {
+	os.RemoveAll(TEST_GRAPH_DIR)
+	for path, content := range map[string]string{
+		"project/example.com/app/main.go":         "package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/a\"\n)\n",
+		"project/example.com/app/sub/sub.go":      "package sub\n\nimport \"example.com/b\"\n",
+		"project/example.com/app/sub/sub_test.go": "package sub\n\nimport \"example.com/unused\"\n",
+		"project/example.com/app/_vendor/x/x.go":  "package x\n\nimport \"example.com/unused\"\n",
+		"vendor/example.com/a/a.go":               "package a\n\nimport \"example.com/c\"\n",
+		"vendor/example.com/b/b.go":               "package b\n\nimport \"example.com/missing\"\n",
+		"vendor/example.com/c/c.go":               "package c\n",
+		"vendor/example.com/unused/unused.go":     "package unused\n",
+	} {
+		path = filepath.Join(TEST_GRAPH_DIR, path)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(content), 0644)
+	}
+}
+
+func test_graph_TearDown() {
+	os.RemoveAll(TEST_GRAPH_DIR)
+}
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+func Test_Ignore_Match(t *testing.T) {
+	ignore := util.NewIgnore(".", []string{
+		"# comment",
+		"*.log",
+		"!keep.log",
+		"node_modules/",
+		"/build",
+		"docs/*.md",
+		"**/testdata/**",
+		"a/**/z",
+		"\\#hash",
+	})
+
+	for path, expected := range map[string]bool{
+		"app.log":             true,
+		"sub/app.log":         true,
+		"keep.log":            false,
+		"sub/keep.log":        false,
+		"node_modules":        true,
+		"web/node_modules":    true,
+		"build":               true,
+		"sub/build":           false,
+		"docs/readme.md":      true,
+		"docs/sub/readme.md":  false,
+		"pkg/testdata/in.txt": true,
+		"a/z":                 true,
+		"a/b/c/z":             true,
+		"#hash":               true,
+		"comment":             false,
+		"main.go":             false,
+		".git":                true,
+		".gopath":             true,
+		"sub/_vendor":         false,
+		"_vendor":             true,
+		"pkg/testdata.go":     false,
+		"node_modules.go":     false,
+		"docs/readme.md.orig": false,
+	} {
+		isDir := path == "node_modules" || path == "web/node_modules"
+		if ignore.Match(path, isDir) != expected {
+			t.Errorf("Wrong match of %s, expected %v", path, expected)
+		}
+	}
+
+	if ignore.Match("node_modules", false) {
+		t.Error("Dir only pattern must not match file")
+	}
+}
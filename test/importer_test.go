@@ -0,0 +1,259 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_IMPORTER_DIR = ".tmp/importer"
+)
+
+func Test_Importer_Godep(t *testing.T) {
+	test_importer_SetUp(map[string]string{
+		"Godeps/Godeps.json": `{
+	"ImportPath": "example.com/app",
+	"GoVersion": "go1.8",
+	"Deps": [
+		{"ImportPath": "example.com/foo/sub", "Rev": "aaaa"},
+		{"ImportPath": "example.com/foo", "Comment": "v1.0.0", "Rev": "aaaa"},
+		{"ImportPath": "example.com/bar", "Rev": "bbbb"}
+	]
+}`,
+	})
+	defer test_importer_TearDown()
+
+	test_importer_Assert(t, "godep", "name: example.com/app\n\ndependencies:\n  - example.com/bar=bbbb\n  - example.com/foo=aaaa\n")
+}
+
+func Test_Importer_Glide(t *testing.T) {
+	test_importer_SetUp(map[string]string{
+		"glide.yaml": `package: example.com/app
+import:
+- package: example.com/foo
+  version: ^1.2.0
+- package: example.com/private
+  repo: git@host:private.git
+`,
+	})
+	defer test_importer_TearDown()
+
+	test_importer_Assert(t, "glide", `name: example.com/app
+
+dependencies:
+  - example.com/foo=^1.2.0
+  - name: example.com/private
+    git: git@host:private.git
+`)
+
+	ioutil.WriteFile(filepath.Join(TEST_IMPORTER_DIR, "glide.lock"), []byte(`hash: x
+imports:
+- name: example.com/foo
+  version: aaaa
+testImports:
+- name: example.com/assert
+  version: bbbb
+`), 0644)
+	os.Remove(filepath.Join(TEST_IMPORTER_DIR, "gopas.yml"))
+
+	test_importer_Assert(t, "glide", "name: example.com/app\n\ndependencies:\n  - example.com/assert=bbbb\n  - example.com/foo=aaaa\n")
+}
+
+func Test_Importer_Dep(t *testing.T) {
+	test_importer_SetUp(map[string]string{
+		"Gopkg.toml": `# Gopkg.toml example
+required = ["example.com/tool"]
+
+[[constraint]]
+  name = "example.com/foo"
+  version = "1.2.0"
+
+[[constraint]]
+  name = "example.com/bar"
+  branch = "master" # tracking
+
+[[override]]
+  name = "example.com/baz"
+  version = "=2.0.0"
+
+[prune]
+  go-tests = true
+`,
+	})
+	defer test_importer_TearDown()
+
+	test_importer_Assert(t, "dep", "dependencies:\n  - example.com/bar=master\n  - example.com/foo=^1.2.0\n")
+
+	ioutil.WriteFile(filepath.Join(TEST_IMPORTER_DIR, "Gopkg.lock"), []byte(`[[projects]]
+  name = "example.com/foo"
+  packages = [
+    ".",
+    "sub"
+  ]
+  revision = "aaaa"
+  version = "v1.2.3"
+
+[[projects]]
+  name = "example.com/fork"
+  packages = ["."]
+  revision = "bbbb"
+  source = "https://example.com/me/fork.git"
+
+[[projects]]
+  name = "example.com/legacy"
+  packages = ["."]
+  revision = "1234"
+  source = "https://example.com/me/legacy"
+
+[[projects]]
+  name = "example.com/mercurial"
+  packages = ["."]
+  revision = "cccc"
+  source = "https://hg.example.com/mercurial"
+
+[solve-meta]
+  inputs-digest = "x"
+`), 0644)
+	os.Remove(filepath.Join(TEST_IMPORTER_DIR, "gopas.yml"))
+
+	test_importer_Assert(t, "dep", `dependencies:
+  - example.com/foo=aaaa
+  - name: example.com/fork
+    version: bbbb
+    git: https://example.com/me/fork.git
+  - name: example.com/legacy
+    version: 1234
+    svn: https://example.com/me/legacy
+  - name: example.com/mercurial
+    version: cccc
+    hg: https://hg.example.com/mercurial
+`)
+}
+
+func Test_Importer_Govendor(t *testing.T) {
+	test_importer_SetUp(map[string]string{
+		"vendor/vendor.json": `{
+	"rootPath": "example.com/app",
+	"package": [
+		{"path": "example.com/foo", "revision": "aaaa"},
+		{"path": "example.com/foo/sub", "revision": "aaaa"},
+		{"path": "example.com/bar", "origin": "example.com/me/bar", "revision": "bbbb"}
+	]
+}`,
+	})
+	defer test_importer_TearDown()
+
+	test_importer_Assert(t, "govendor", "name: example.com/app\n\ndependencies:\n  - example.com/foo=aaaa\n  - example.com/me/bar=bbbb\n")
+}
+
+func Test_Importer_GoMod(t *testing.T) {
+	test_importer_SetUp(map[string]string{
+		"go.mod": `module example.com/app
+
+go 1.12
+
+require (
+	example.com/foo v1.2.0
+	example.com/bar v0.0.0-20190102030405-abcdefabcdef // indirect
+	example.com/baz v2.0.1+incompatible
+	"example.com/qux" v1.0.1-0.20190102030405-0123456789ab
+	example.com/local v0.0.0-00010101000000-000000000000
+)
+
+replace example.com/local => ./local
+
+replace example.com/foo v1.2.0 => example.com/fork v1.2.1
+`,
+		"glide.yaml": "package: example.com/glide\n",
+	})
+	defer test_importer_TearDown()
+
+	manifest, _, err := util.ImportManifest(TEST_IMPORTER_DIR, "")
+	if err != nil || manifest.Name != "example.com/glide" {
+		t.Errorf("Other manifest must be found before go.mod %v %v", manifest, err)
+		return
+	}
+
+	manifest, source, err := util.ImportManifest(TEST_IMPORTER_DIR, "gomod")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if source != "gomod" || manifest.Name != "example.com/app" {
+		t.Errorf("Wrong manifest %s %v", source, manifest)
+	}
+
+	expected := []string{
+		"example.com/baz=v2.0.1",
+		"example.com/foo=v1.2.0",
+		"example.com/local => local",
+		"example.com/qux=0123456789ab",
+	}
+	if len(manifest.Dependencies) != len(expected) {
+		t.Errorf("Wrong dependencies %v", manifest.Dependencies)
+		return
+	}
+	for i, dep := range manifest.Dependencies {
+		if dep.String() != expected[i] {
+			t.Errorf("Wrong dependency %s, expected %s", dep, expected[i])
+		}
+	}
+
+	if _, _, err = util.ImportManifest(TEST_IMPORTER_DIR, "dep"); err == nil {
+		t.Error("Must fail without dep manifest")
+	}
+	if _, _, err = util.ImportManifest(TEST_IMPORTER_DIR, "bower"); err == nil {
+		t.Error("Must fail with unknown source")
+	}
+}
+
+func Test_Importer_Missing(t *testing.T) {
+	test_importer_SetUp(map[string]string{})
+	defer test_importer_TearDown()
+
+	if _, _, err := util.ImportManifest(TEST_IMPORTER_DIR, ""); err == nil {
+		t.Error("Must fail without manifest")
+	}
+}
+
+func test_importer_SetUp(files map[string]string) {
+	os.RemoveAll(TEST_IMPORTER_DIR)
+	os.MkdirAll(TEST_IMPORTER_DIR, 0755)
+	for file, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(TEST_IMPORTER_DIR, file)), 0755)
+		ioutil.WriteFile(filepath.Join(TEST_IMPORTER_DIR, file), []byte(content), 0644)
+	}
+}
+
+func test_importer_TearDown() {
+	os.RemoveAll(TEST_IMPORTER_DIR)
+}
+
+func test_importer_Assert(t *testing.T, source string, expected string) {
+	manifest, found, err := util.ImportManifest(TEST_IMPORTER_DIR, "")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if found != source {
+		t.Errorf("Wrong source %s", found)
+	}
+
+	if err = util.WriteManifest(TEST_IMPORTER_DIR, manifest); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	content, _ := ioutil.ReadFile(filepath.Join(TEST_IMPORTER_DIR, "gopas.yml"))
+	if string(content) != expected {
+		t.Errorf("Wrong gopas.yml:\n%s", content)
+	}
+
+	if _, err = util.ReadManifest(TEST_IMPORTER_DIR); err != nil {
+		t.Error(err.Error())
+	}
+}
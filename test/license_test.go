@@ -0,0 +1,95 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_LICENSE_DIR = ".tmp/license"
+)
+
+func Test_License_Classify(t *testing.T) {
+	texts := map[string]string{
+		"MIT": `Permission is hereby granted, free of charge, to any person obtaining
+a copy of this software`,
+		"BSD-3-Clause": `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Neither the name of Google Inc. nor the names of its contributors may be
+used to endorse or promote products derived from this software`,
+		"BSD-2-Clause": `Redistribution and use in source and binary forms, with or without
+modification, are permitted`,
+		"Apache-2.0": `                                 Apache License
+                           Version 2.0, January 2004`,
+		"GPL": `GNU GENERAL PUBLIC LICENSE
+Version 3, 29 June 2007`,
+		"LGPL": `GNU LESSER GENERAL PUBLIC LICENSE
+Version 3, 29 June 2007`,
+		"unknown": "All rights reserved.",
+	}
+
+	for expected, text := range texts {
+		if license := util.ClassifyLicense(text); license != expected {
+			t.Errorf("Wrong license %s, expected %s", license, expected)
+		}
+	}
+}
+
+func Test_License_Scan(t *testing.T) {
+	os.RemoveAll(TEST_LICENSE_DIR)
+	defer os.RemoveAll(TEST_LICENSE_DIR)
+
+	for name, files := range map[string]map[string]string{
+		"example.com/mit":  {"LICENSE.md": "Permission is hereby granted, free of charge"},
+		"example.com/gpl":  {"README": "readme", "COPYING": "GNU General Public License"},
+		"example.com/none": {"foo.go": "package foo"},
+	} {
+		os.MkdirAll(filepath.Join(TEST_LICENSE_DIR, name), 0755)
+		for file, content := range files {
+			ioutil.WriteFile(filepath.Join(TEST_LICENSE_DIR, name, file), []byte(content), 0644)
+		}
+	}
+
+	lock := &util.Lock{Packages: []util.LockedPackage{
+		{Name: "example.com/mit", Revision: "1"},
+		{Name: "example.com/gpl", Revision: "2"},
+		{Name: "example.com/none", Revision: "3"},
+	}}
+	licenses, err := util.ScanLicenses(TEST_LICENSE_DIR, lock)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	expected := []util.License{
+		{Name: "example.com/gpl", Revision: "2", Type: "GPL", File: "COPYING"},
+		{Name: "example.com/mit", Revision: "1", Type: "MIT", File: "LICENSE.md"},
+		{Name: "example.com/none", Revision: "3", Type: "unknown"},
+	}
+	if len(licenses) != len(expected) {
+		t.Errorf("Wrong licenses %v", licenses)
+		return
+	}
+	for i, license := range licenses {
+		if *license != expected[i] {
+			t.Errorf("Wrong license %v, expected %v", *license, expected[i])
+		}
+	}
+}
+
+func Test_License_Policy(t *testing.T) {
+	policy := util.LicensePolicy{Allow: []string{"MIT", "BSD-3-Clause"}, Deny: []string{"GPL"}}
+	for license, allowed := range map[string]bool{"mit": true, "BSD-3-Clause": true, "GPL": false, "unknown": false} {
+		if policy.Allows(license) != allowed {
+			t.Errorf("Wrong policy for %s", license)
+		}
+	}
+
+	if !(util.LicensePolicy{Deny: []string{"GPL"}}).Allows("unknown") {
+		t.Error("Only denied licenses must fail without allow list")
+	}
+}
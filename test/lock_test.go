@@ -0,0 +1,157 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+func Test_Lock_WriteAndRead(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	deps := []util.Dependency{
+		{Name: "example.com/foo/sub", Version: "v1.0.0"},
+	}
+
+	lock, err := util.NewLock(filepath.Join(TEST_VCS_DIR, "src"), deps)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if len(lock.Packages) != 1 || lock.Packages[0].Name != "example.com/foo" || lock.Packages[0].Vcs != "git" {
+		t.Errorf("Wrong locked packages %v", lock.Packages)
+		return
+	}
+
+	if err = lock.Write(TEST_VCS_DIR); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	read, err := util.ReadLock(TEST_VCS_DIR)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if read.Packages[0].Revision != lock.Packages[0].Revision || read.Packages[0].Remote != lock.Packages[0].Remote {
+		t.Error("Read lock does not match written lock")
+	}
+
+	if !read.Matches(deps) {
+		t.Error("Lock must match its dependencies")
+	}
+
+	if read.Matches([]util.Dependency{{Name: "example.com/foo/sub", Version: "v2.0.0"}}) {
+		t.Error("Lock must not match changed dependencies")
+	}
+}
+
+func Test_Lock_ReadNotExist(t *testing.T) {
+	if lock, err := util.ReadLock(TEST_VCS_DIR); lock != nil || err != nil {
+		t.Error("Missing lock must be nil without error")
+	}
+}
+
+func Test_Lock_NestedRoots(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	vendorDir := filepath.Join(TEST_VCS_DIR, "src")
+	origin, _ := filepath.Abs(filepath.Join(TEST_VCS_DIR, "origin"))
+	test_vcs_Git(t, vendorDir, "clone", "-q", origin, "example.com/foo/nested")
+	test_prune_WriteFiles(filepath.Join(vendorDir, "example.com/foo/v2"), map[string]string{
+		"v2.go":        "package v2\n",
+		util.PROXYMETA: "path: example.com/foo/v2\nversion: v2.0.0\nproxy: file:///proxy\n",
+	})
+
+	lock, err := util.NewLock(vendorDir, nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	names := []string{}
+	for _, pkg := range lock.Packages {
+		names = append(names, pkg.Name+" "+pkg.Vcs)
+	}
+	if strings.Join(names, ",") != "example.com/foo git,example.com/foo/nested git,example.com/foo/v2 proxy" {
+		t.Errorf("Nested roots must be locked on their own, got %v", names)
+		return
+	}
+
+	nested := filepath.Join(vendorDir, "example.com/foo/nested")
+	os.RemoveAll(nested)
+	if err = lock.Restore(vendorDir, nil); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if rev := test_vcs_Git(t, nested, "rev-parse", "HEAD"); rev != lock.Packages[1].Revision {
+		t.Errorf("Restored nested revision %s does not match locked %s", rev, lock.Packages[1].Revision)
+	}
+}
+
+func Test_Lock_Restore(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	vendorDir := filepath.Join(TEST_VCS_DIR, "src")
+	dir := filepath.Join(vendorDir, "example.com/foo")
+	test_vcs_Git(t, dir, "checkout", "-q", "v1.0.0")
+
+	lock, err := util.NewLock(vendorDir, nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	os.RemoveAll(dir)
+
+	if err = lock.Restore(vendorDir, nil); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if rev := test_vcs_Git(t, dir, "rev-parse", "HEAD"); rev != lock.Packages[0].Revision {
+		t.Errorf("Restored revision %s does not match locked %s", rev, lock.Packages[0].Revision)
+	}
+}
+
+func Test_Lock_RestoreFailure(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	vendorDir := filepath.Join(TEST_VCS_DIR, "src")
+	dir := filepath.Join(vendorDir, "example.com/foo")
+	test_vcs_Git(t, dir, "checkout", "-q", "v1.0.0")
+
+	lock, err := util.NewLock(vendorDir, nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	lock.Packages = append([]util.LockedPackage{
+		{Name: "example.com/bar", Vcs: "git", Remote: filepath.Join(TEST_VCS_DIR, "missing"), Revision: lock.Packages[0].Revision},
+	}, lock.Packages...)
+
+	os.RemoveAll(dir)
+
+	err = lock.Restore(vendorDir, nil)
+	restoreErr, ok := err.(*util.RestoreError)
+	if !ok || len(restoreErr.Packages) != 1 || restoreErr.Packages[0].Name != "example.com/bar" {
+		t.Errorf("Wrong restore error %v", err)
+		return
+	}
+	if restoreErr.Error() != "1 of 2 locked packages failed to restore" {
+		t.Errorf("Wrong summary %s", restoreErr.Error())
+	}
+
+	if rev := test_vcs_Git(t, dir, "rev-parse", "HEAD"); rev != lock.Packages[1].Revision {
+		t.Error("Failing package must not stop restoring the rest")
+	}
+}
@@ -0,0 +1,276 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_MANIFEST_DIR = ".tmp/manifest"
+)
+
+func Test_Manifest_ReadRange(t *testing.T) {
+	test_manifest_SetUp("gopas.yml", "name: example.com/app\n\ndependencies:\n  - example.com/foo=>=2.0 <3\n")
+	defer test_manifest_TearDown()
+
+	manifest, err := util.ReadManifest(TEST_MANIFEST_DIR)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if len(manifest.Dependencies) != 1 || manifest.Dependencies[0].Version != ">=2.0 <3" {
+		t.Errorf("Wrong dependencies %v", manifest.Dependencies)
+	}
+}
+
+func Test_Manifest_AddAndRemoveYaml(t *testing.T) {
+	test_manifest_SetUp("gopas.yml", `# project
+name: example.com/app
+
+dependencies:
+  # database
+  - example.com/foo # pinned later
+  - example.com/bar=v1.0.0
+
+pre-build:
+  - ["echo", "hi"]
+`)
+	defer test_manifest_TearDown()
+
+	if err := util.AddDependency(TEST_MANIFEST_DIR, util.Dependency{Name: "example.com/baz", Version: "^1.2"}); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if err := util.AddDependency(TEST_MANIFEST_DIR, util.Dependency{Name: "example.com/foo"}); err == nil {
+		t.Error("Must refuse duplicate dependency")
+	}
+
+	if err := util.RemoveDependency(TEST_MANIFEST_DIR, "example.com/foo"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if err := util.RemoveDependency(TEST_MANIFEST_DIR, "example.com/foo"); err == nil {
+		t.Error("Must fail removing unknown dependency")
+	}
+
+	expected := `# project
+name: example.com/app
+
+dependencies:
+  # database
+  - example.com/bar=v1.0.0
+  - example.com/baz=^1.2
+
+pre-build:
+  - ["echo", "hi"]
+`
+	if content := test_manifest_Read("gopas.yml"); content != expected {
+		t.Errorf("Wrong gopas.yml:\n%s", content)
+	}
+
+	manifest, _ := util.ReadManifest(TEST_MANIFEST_DIR)
+	if manifest.Name != "example.com/app" || len(manifest.PreBuild) != 1 || len(manifest.Dependencies) != 2 {
+		t.Errorf("Wrong manifest %v", manifest)
+	}
+}
+
+func Test_Manifest_AddWithoutDependencies(t *testing.T) {
+	test_manifest_SetUp("gopas.yml", "name: example.com/app\n")
+	defer test_manifest_TearDown()
+
+	if err := util.AddDependency(TEST_MANIFEST_DIR, util.Dependency{Name: "example.com/foo"}); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if content := test_manifest_Read("gopas.yml"); content != "name: example.com/app\n\ndependencies:\n  - example.com/foo\n" {
+		t.Errorf("Wrong gopas.yml:\n%s", content)
+	}
+}
+
+func Test_Manifest_AddAndRemoveGopasfile(t *testing.T) {
+	test_manifest_SetUp("gopasfile", "example.com/foo\nexample.com/bar=v1.0.0\n")
+	defer test_manifest_TearDown()
+
+	if err := util.AddDependency(TEST_MANIFEST_DIR, util.Dependency{Name: "example.com/baz", Version: "v2"}); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if err := util.RemoveDependency(TEST_MANIFEST_DIR, "example.com/bar"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if content := test_manifest_Read("gopasfile"); content != "example.com/foo\nexample.com/baz=v2\n" {
+		t.Errorf("Wrong gopasfile:\n%s", content)
+	}
+
+	if _, err := os.Stat(filepath.Join(TEST_MANIFEST_DIR, "gopas.yml")); !os.IsNotExist(err) {
+		t.Error("Must not create gopas.yml")
+	}
+}
+
+func Test_Manifest_ExplicitRemote(t *testing.T) {
+	test_manifest_SetUp("gopas.yml", `name: example.com/app
+
+dependencies:
+  - example.com/foo
+  - name: example.com/x
+    version: ^1.0
+    git: ssh://git@host/x.git
+`)
+	defer test_manifest_TearDown()
+
+	manifest, err := util.ReadManifest(TEST_MANIFEST_DIR)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if len(manifest.Dependencies) != 2 {
+		t.Errorf("Wrong dependencies %v", manifest.Dependencies)
+		return
+	}
+	if dep := manifest.Dependencies[1]; dep.Name != "example.com/x" || dep.Version != "^1.0" || dep.Vcs != "git" || dep.Remote != "ssh://git@host/x.git" {
+		t.Errorf("Wrong dependency %v", dep)
+	}
+
+	if err = util.AddDependency(TEST_MANIFEST_DIR, util.Dependency{Name: "example.com/bar"}); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err = util.RemoveDependency(TEST_MANIFEST_DIR, "example.com/x"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if content := test_manifest_Read("gopas.yml"); content != "name: example.com/app\n\ndependencies:\n  - example.com/foo\n  - example.com/bar\n" {
+		t.Errorf("Wrong gopas.yml:\n%s", content)
+	}
+}
+
+func Test_Manifest_ExplicitRemoteConflict(t *testing.T) {
+	test_manifest_SetUp("gopas.yml", "dependencies:\n  - name: example.com/x\n    git: file:///x\n    hg: file:///x\n")
+	defer test_manifest_TearDown()
+
+	if _, err := util.ReadManifest(TEST_MANIFEST_DIR); err == nil {
+		t.Error("Must refuse more than one remote")
+	}
+}
+
+func Test_Manifest_SetDependencyVersion(t *testing.T) {
+	test_manifest_SetUp("gopas.yml", `name: example.com/app
+
+dependencies:
+  - example.com/foo=^1.0 # pinned below 2
+  - name: example.com/x
+    version: ~0.4
+    git: ssh://git@host/x.git
+  - example.com/bar
+`)
+	defer test_manifest_TearDown()
+
+	for name, version := range map[string]string{"example.com/foo": "^1.3.0", "example.com/x": "~0.4.2"} {
+		if err := util.SetDependencyVersion(TEST_MANIFEST_DIR, name, version); err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+	if err := util.SetDependencyVersion(TEST_MANIFEST_DIR, "example.com/none", "v1"); err == nil {
+		t.Error("Must fail setting version of unknown dependency")
+	}
+
+	expected := `name: example.com/app
+
+dependencies:
+  - example.com/foo=^1.3.0 # pinned below 2
+  - name: example.com/x
+    version: ~0.4.2
+    git: ssh://git@host/x.git
+  - example.com/bar
+`
+	if content := test_manifest_Read("gopas.yml"); content != expected {
+		t.Errorf("Wrong gopas.yml:\n%s", content)
+	}
+}
+
+func Test_Manifest_WriteEmptyScalar(t *testing.T) {
+	test_manifest_SetUp("gopas.yml", "")
+	defer test_manifest_TearDown()
+
+	if err := util.WriteManifest(TEST_MANIFEST_DIR, &util.Manifest{Name: "example.com/app", Ignore: []string{""}}); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	manifest, err := util.ReadManifest(TEST_MANIFEST_DIR)
+	if err != nil || len(manifest.Ignore) != 1 || manifest.Ignore[0] != "" {
+		t.Errorf("Empty string must be written quoted %v %v", manifest, err)
+	}
+}
+
+func Test_Manifest_Licenses(t *testing.T) {
+	content := `name: example.com/app
+
+dependencies:
+  - example.com/foo
+
+licenses:
+  allow:
+    - MIT
+    - Apache-2.0
+  deny:
+    - GPL
+`
+	test_manifest_SetUp("gopas.yml", content)
+	defer test_manifest_TearDown()
+
+	manifest, err := util.ReadManifest(TEST_MANIFEST_DIR)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(manifest.Licenses.Allow) != 2 || len(manifest.Licenses.Deny) != 1 || manifest.Licenses.Deny[0] != "GPL" {
+		t.Errorf("Wrong licenses %v", manifest.Licenses)
+	}
+
+	if err = util.AddDependency(TEST_MANIFEST_DIR, util.Dependency{Name: "example.com/bar"}); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err = util.RemoveDependency(TEST_MANIFEST_DIR, "example.com/bar"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err = util.WriteManifest(TEST_MANIFEST_DIR, manifest); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if written := test_manifest_Read("gopas.yml"); written != content {
+		t.Errorf("Wrong gopas.yml:\n%s", written)
+	}
+}
+
+func test_manifest_SetUp(file string, content string) {
+	os.RemoveAll(TEST_MANIFEST_DIR)
+	os.MkdirAll(TEST_MANIFEST_DIR, 0755)
+	ioutil.WriteFile(filepath.Join(TEST_MANIFEST_DIR, file), []byte(content), 0644)
+}
+
+func test_manifest_TearDown() {
+	os.RemoveAll(TEST_MANIFEST_DIR)
+}
+
+func test_manifest_Read(file string) string {
+	content, _ := ioutil.ReadFile(filepath.Join(TEST_MANIFEST_DIR, file))
+	return string(content)
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+func Test_Outdated_CheckOutdated(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	vendorDir := filepath.Join(TEST_VCS_DIR, "src")
+	dir := filepath.Join(vendorDir, "example.com/foo")
+	origin := filepath.Join(TEST_VCS_DIR, "origin")
+
+	test_vcs_Git(t, origin, "tag", "v1.1.0")
+	test_vcs_Git(t, origin, "tag", "v2.0.0-rc.1")
+	test_vcs_Git(t, dir, "fetch", "-q", "--tags", "origin")
+	test_vcs_Git(t, dir, "checkout", "-q", "v1.0.0")
+
+	report, err := util.CheckOutdated(vendorDir, util.Dependency{Name: "example.com/foo/sub", Version: "^1.0"}, false)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if report.Current != "v1.0.0" || report.Wanted != "v1.1.0" || report.Latest != "v1.1.0" || !report.Outdated {
+		t.Errorf("Wrong report %v", report)
+	}
+
+	report, _ = util.CheckOutdated(vendorDir, util.Dependency{Name: "example.com/foo", Version: "v1.0.0"}, false)
+	if report.Wanted != "v1.0.0" || report.Outdated {
+		t.Errorf("Pinned dependency must be up to date %v", report)
+	}
+
+	test_vcs_Git(t, dir, "remote", "set-head", "origin", "-d")
+	report, err = util.CheckOutdated(vendorDir, util.Dependency{Name: "example.com/foo"}, false)
+	if err != nil || report.Current != "v1.0.0" || report.Wanted != "" || report.Latest != "v1.1.0" || report.Outdated {
+		t.Errorf("Dependency without default branch must report wanted unknown %v %v", report, err)
+	}
+
+	report, _ = util.CheckOutdated(vendorDir, util.Dependency{Name: "example.com/missing"}, false)
+	if report.Current != "" || report.Outdated {
+		t.Errorf("Missing dependency must report nothing %v", report)
+	}
+}
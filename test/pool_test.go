@@ -0,0 +1,48 @@
+package test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+func Test_Pool_RunPool(t *testing.T) {
+	var running, peak int32
+
+	failed := map[int]bool{}
+	done := 0
+	util.RunPool(3, 10, func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		if i%4 == 0 {
+			return errors.New("fail")
+		}
+		return nil
+	}, func(i int, err error) {
+		done++
+		if err != nil {
+			failed[i] = true
+		}
+	})
+
+	if done != 10 {
+		t.Errorf("Wrong finished jobs %d", done)
+	}
+	if peak > 3 || peak < 2 {
+		t.Errorf("Wrong parallel jobs %d", peak)
+	}
+	if len(failed) != 3 || !failed[0] || !failed[4] || !failed[8] {
+		t.Errorf("Wrong failed jobs %v", failed)
+	}
+}
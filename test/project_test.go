@@ -1,9 +1,15 @@
 package test
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/reekoheek/gopas/util"
@@ -17,14 +23,7 @@ func Test_Project_Dependencies(t *testing.T) {
 	test_project_SetUp()
 	defer test_project_TearDown()
 
-	project, err := (&util.ProjectImpl{
-		Cwd: TEST_PROJECT_CWD,
-	}).Construct(nil)
-
-	if err != nil {
-		t.Error(err.Error())
-		return
-	}
+	project := util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
 
 	ioutil.WriteFile(
 		filepath.Join(TEST_PROJECT_CWD, "gopasfile"),
@@ -50,3 +49,379 @@ func test_project_SetUp() {
 func test_project_TearDown() {
 	os.RemoveAll(TEST_PROJECT_CWD)
 }
+
+func Test_Project_LocalDependency(t *testing.T) {
+	test_project_SetUp()
+	defer test_project_TearDown()
+
+	libDir := filepath.Join(TEST_PROJECT_CWD, "..", "lib")
+	os.MkdirAll(libDir, 0755)
+	defer os.RemoveAll(libDir)
+	ioutil.WriteFile(filepath.Join(libDir, "lib.go"), []byte("package lib\n"), 0644)
+
+	ioutil.WriteFile(
+		filepath.Join(TEST_PROJECT_CWD, "gopas.yml"),
+		[]byte("name: example.com/app\n\ndependencies:\n  - example.com/lib => ../lib\n"),
+		0644)
+
+	project := util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
+	dependencies := project.Dependencies()
+	if len(dependencies) != 1 || dependencies[0].Path != "../lib" || dependencies[0].Version != "" {
+		t.Errorf("Wrong dependencies %v", dependencies)
+		return
+	}
+
+	linked := filepath.Join(TEST_PROJECT_CWD, ".gopath", "src", "example.com", "lib", "lib.go")
+	if _, err := os.Stat(linked); err != nil {
+		t.Error("Local dependency not linked into gopath")
+		return
+	}
+
+	ioutil.WriteFile(filepath.Join(libDir, "edit.go"), []byte("package lib\n"), 0644)
+	if _, err := os.Stat(filepath.Join(filepath.Dir(linked), "edit.go")); err != nil {
+		t.Error("Edit of local dependency not visible")
+	}
+}
+
+func Test_Project_ModuleMode(t *testing.T) {
+	test_project_SetUp()
+	defer test_project_TearDown()
+
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "go.mod"), []byte("module example.com/app\n"), 0644)
+
+	project := util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
+	if !project.IsModule() {
+		t.Error("Project with go.mod must be module")
+		return
+	}
+	if project.Name() != "example.com/app" {
+		t.Errorf("Wrong name %s", project.Name())
+	}
+	if project.Dir() != project.Cwd {
+		t.Errorf("Wrong dir %s", project.Dir())
+	}
+	if _, err := os.Stat(filepath.Join(TEST_PROJECT_CWD, ".gopath")); !os.IsNotExist(err) {
+		t.Error("Gopath must not be created in module mode")
+	}
+}
+
+func Test_Project_Sync(t *testing.T) {
+	test_project_SetUp()
+	defer test_project_TearDown()
+
+	os.MkdirAll(filepath.Join(TEST_PROJECT_CWD, "node_modules/foo"), 0755)
+	os.MkdirAll(filepath.Join(TEST_PROJECT_CWD, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "main.go"), []byte("package main\n"), 0644)
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, ".git/HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "node_modules/foo/index.js"), []byte(""), 0644)
+	os.MkdirAll(filepath.Join(TEST_PROJECT_CWD, "fixtures/keep"), 0755)
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "fixtures/big.json"), []byte("{}"), 0644)
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "fixtures/keep/small.json"), []byte("{}"), 0644)
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, util.GOPASIGNORE), []byte("# fixtures\n/fixtures/*\n!/fixtures/keep/\n"), 0644)
+	ioutil.WriteFile(
+		filepath.Join(TEST_PROJECT_CWD, "gopas.yml"),
+		[]byte("name: example.com/app\n\nignore:\n  - node_modules\n"),
+		0644)
+
+	project := util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
+	if err := project.Bootstrap(); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	for _, synced := range []string{"main.go", "fixtures/keep/small.json"} {
+		if _, err := os.Stat(filepath.Join(project.Dir(), synced)); err != nil {
+			t.Errorf("%s not synced", synced)
+		}
+	}
+	for _, ignored := range []string{".git", "node_modules", ".gopath", "_vendor", "fixtures/big.json"} {
+		if _, err := os.Stat(filepath.Join(project.Dir(), ignored)); !os.IsNotExist(err) {
+			t.Errorf("%s must not be synced", ignored)
+		}
+	}
+}
+
+func Test_Project_SymlinkLayout(t *testing.T) {
+	test_project_SetUp()
+	defer test_project_TearDown()
+
+	os.MkdirAll(filepath.Join(TEST_PROJECT_CWD, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "main.go"), []byte("package main\n"), 0644)
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, ".git/HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+	ioutil.WriteFile(
+		filepath.Join(TEST_PROJECT_CWD, "gopas.yml"),
+		[]byte("name: example.com/app\nlayout: symlink\n"),
+		0644)
+
+	project := util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
+	if err := project.Bootstrap(); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if target, err := os.Readlink(project.Dir()); err != nil || target != project.Cwd {
+		t.Errorf("Project must be linked into gopath %s %v", target, err)
+		return
+	}
+
+	graph, err := project.Graph()
+	if err != nil || len(graph.Roots()) != 1 || graph.Roots()[0] != "example.com/app" {
+		t.Errorf("Linked project must be walked %v %v", graph, err)
+	}
+
+	ioutil.WriteFile(
+		filepath.Join(TEST_PROJECT_CWD, "gopas.yml"),
+		[]byte("name: example.com/app\nlayout: copy\n"),
+		0644)
+	project = util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
+	if err := project.Bootstrap(); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if fi, err := os.Lstat(project.Dir()); err != nil || !fi.IsDir() {
+		t.Error("Project must be copied back into gopath")
+	}
+	if _, err := os.Stat(filepath.Join(TEST_PROJECT_CWD, ".git/HEAD")); err != nil {
+		t.Error("Switching layout must not touch project files")
+	}
+}
+
+func Test_Project_RewriteImports(t *testing.T) {
+	test_project_SetUp()
+	defer test_project_TearDown()
+
+	mirror, _ := filepath.Abs(filepath.Join(TEST_PROJECT_CWD, "..", "mirror"))
+	os.RemoveAll(mirror)
+	defer os.RemoveAll(mirror)
+	test_project_BareRepo(t, filepath.Join(mirror, "app.git"), map[string]string{
+		"app.go": "package app\n\nimport _ \"example.org/lib\"\n",
+	})
+	test_project_BareRepo(t, filepath.Join(mirror, "lib.git"), map[string]string{
+		"lib.go": "package lib\n",
+	})
+
+	hosts, restore := test_project_Discovery(map[string]string{
+		"example.com/app": "git file://" + filepath.ToSlash(filepath.Join(mirror, "app.git")),
+	})
+	defer restore()
+
+	rewrite, err := util.ParseRewrite("example.org/* => file://" + filepath.ToSlash(mirror) + "/*.git")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "gopas.yml"), []byte("name: example.com/main\n"), 0644)
+	project := util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
+	project.Config = &util.Config{Rewrites: []util.Rewrite{rewrite}}
+	project.Cache = util.NewCache(filepath.Join(mirror, "cache"), false)
+
+	if err = project.Get(util.Dependency{Name: "example.com/app"}); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	lib := filepath.Join(project.VendorDir(), "example.org/lib")
+	if remote, err := util.VcsGit.Remote(lib); err != nil || !strings.HasPrefix(remote, "file://"+filepath.ToSlash(mirror)) {
+		t.Errorf("Import must be fetched from mirror, got %s %v", remote, err)
+	}
+	if len(*hosts) == 0 {
+		t.Error("Repository of example.com/app must be discovered")
+	}
+	for _, host := range *hosts {
+		if host != "example.com" {
+			t.Errorf("Original host %s must not be contacted", host)
+		}
+	}
+}
+
+func Test_Project_GetVersion(t *testing.T) {
+	test_project_SetUp()
+	defer test_project_TearDown()
+
+	repos, _ := filepath.Abs(filepath.Join(TEST_PROJECT_CWD, "..", "repos"))
+	os.RemoveAll(repos)
+	defer os.RemoveAll(repos)
+
+	work := filepath.Join(repos, "lib")
+	test_prune_WriteFiles(work, map[string]string{"lib.go": "package lib\n\nconst Version = 1\n"})
+	test_vcs_Git(t, work, "init", "-q")
+	test_vcs_Git(t, work, "add", ".")
+	test_vcs_Git(t, work, "commit", "-q", "-m", "first")
+	test_vcs_Git(t, work, "tag", "v1.0.0")
+	tagged := test_vcs_Git(t, work, "rev-parse", "HEAD")
+	test_prune_WriteFiles(work, map[string]string{"lib.go": "package lib\n\nconst Version = 2\n"})
+	test_vcs_Git(t, work, "commit", "-q", "-a", "-m", "second")
+	test_vcs_Git(t, repos, "clone", "-q", "--bare", work, filepath.Join(repos, "lib.git"))
+
+	_, restore := test_project_Discovery(map[string]string{
+		"example.com/lib": "git file://" + filepath.ToSlash(filepath.Join(repos, "lib.git")),
+	})
+	defer restore()
+
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "gopas.yml"), []byte("name: example.com/main\n"), 0644)
+	project := util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
+	project.Config = &util.Config{}
+	project.Cache = util.NewCache(filepath.Join(repos, "cache"), false)
+
+	if err := project.Get(util.Dependency{Name: "example.com/lib", Version: "v1.0.0"}); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	dir := filepath.Join(project.VendorDir(), "example.com/lib")
+	if rev := test_vcs_Git(t, dir, "rev-parse", "HEAD"); rev != tagged {
+		t.Errorf("Revision %s is not pinned tag %s", rev, tagged)
+	}
+	if _, err := os.Stat(filepath.Join(project.Gopath()[1], "pkg")); err != nil {
+		t.Error("Fetched dependency must be installed")
+	}
+
+	if err := project.Get(util.Dependency{Name: "example.com/lib", Version: "v9.9.9"}); err == nil {
+		t.Error("Must fail on unknown version")
+	}
+}
+
+func Test_Project_FetchCachesOnlyCloned(t *testing.T) {
+	test_project_SetUp()
+	defer test_project_TearDown()
+
+	repos, _ := filepath.Abs(filepath.Join(TEST_PROJECT_CWD, "..", "repos"))
+	os.RemoveAll(repos)
+	defer os.RemoveAll(repos)
+	test_project_BareRepo(t, filepath.Join(repos, "lib.git"), map[string]string{"lib.go": "package lib\n"})
+
+	_, restore := test_project_Discovery(map[string]string{
+		"example.com/lib": "git file://" + filepath.ToSlash(filepath.Join(repos, "lib.git")),
+	})
+	defer restore()
+
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "gopas.yml"), []byte("name: example.com/main\n"), 0644)
+	project := util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
+	project.Config = &util.Config{}
+	project.Cache = util.NewCache(filepath.Join(repos, "cache"), false)
+	if err := project.Bootstrap(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// repository another worker is still writing
+	wip := filepath.Join(project.VendorDir(), "example.com/wip")
+	test_prune_WriteFiles(wip, map[string]string{"wip.go": "package wip\n"})
+	test_vcs_Git(t, wip, "init", "-q")
+	test_vcs_Git(t, wip, "remote", "add", "origin", "file:///wip.git")
+
+	if err := project.Get(util.Dependency{Name: "example.com/lib"}); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if !project.Cache.Has("example.com/lib") {
+		t.Error("Cloned repository must be cached")
+	}
+	if project.Cache.Has("example.com/wip") {
+		t.Error("Fetch must not cache repositories it did not clone")
+	}
+}
+
+func Test_Project_UpdateRecordsVersion(t *testing.T) {
+	test_project_SetUp()
+	defer test_project_TearDown()
+
+	origin, _ := filepath.Abs(filepath.Join(TEST_PROJECT_CWD, "..", "origin"))
+	os.RemoveAll(origin)
+	defer os.RemoveAll(origin)
+	test_prune_WriteFiles(origin, map[string]string{"lib.go": "package lib\n"})
+	test_vcs_Git(t, origin, "init", "-q")
+	test_vcs_Git(t, origin, "add", ".")
+	test_vcs_Git(t, origin, "commit", "-q", "-m", "first")
+	test_vcs_Git(t, origin, "tag", "v1.0.0")
+	branch := test_vcs_Git(t, origin, "rev-parse", "--abbrev-ref", "HEAD")
+
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "gopas.yml"), []byte("name: example.com/main\n"), 0644)
+	project := util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
+	project.Cache = util.NewCache(filepath.Join(origin, "..", "cache"), false)
+	if err := project.Bootstrap(); err != nil {
+		t.Fatal(err.Error())
+	}
+	dir := filepath.Join(project.VendorDir(), "example.com/lib")
+	test_vcs_Git(t, project.VendorDir(), "clone", "-q", origin, "example.com/lib")
+
+	// untagged commit is recorded by revision
+	test_prune_WriteFiles(origin, map[string]string{"lib.go": "package lib\n\n// second\n"})
+	test_vcs_Git(t, origin, "commit", "-q", "-a", "-m", "second")
+	second := test_vcs_Git(t, origin, "rev-parse", "HEAD")
+
+	cases := [][2]string{
+		{"", second},
+		{branch, second},
+	}
+	for _, c := range cases {
+		test_vcs_Git(t, dir, "checkout", "-q", "v1.0.0")
+		version, err := project.Update(util.Dependency{Name: "example.com/lib", Version: c[0]})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if version != c[1] {
+			t.Errorf("Update of %q must record %s, got %s", c[0], c[1], version)
+		}
+	}
+
+	// tagged commit is recorded by tag, pinned version is kept
+	test_vcs_Git(t, origin, "tag", "v1.1.0")
+	cases = [][2]string{
+		{"", "v1.1.0"},
+		{branch, "v1.1.0"},
+		{">=1.0.0 <2.0.0", "v1.1.0"},
+		{"^1.0", "^1.1.0"},
+		{"v1.1.0", ""},
+		{second, ""},
+	}
+	for _, c := range cases {
+		version, err := project.Update(util.Dependency{Name: "example.com/lib", Version: c[0]})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if version != c[1] {
+			t.Errorf("Update of %q must record %q, got %q", c[0], c[1], version)
+		}
+	}
+}
+
+// serve go-import meta tags of import paths, with remote written as vcs
+// and url, from server every host resolves to. Returns hosts asked and
+// function restoring discovery client
+func test_project_Discovery(remotes map[string]string) (*[]string, func()) {
+	hosts := []string{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		for importPath, remote := range remotes {
+			if path := r.Host + r.URL.Path; path == importPath || strings.HasPrefix(path, importPath+"/") {
+				fmt.Fprintf(w, `<html><head><meta name="go-import" content="%s %s"></head></html>`, importPath, remote)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	client := util.DiscoverClient
+	util.DiscoverClient = &http.Client{Transport: transport}
+
+	return &hosts, func() {
+		util.DiscoverClient = client
+		server.Close()
+	}
+}
+
+// create bare repository at dir holding files in one commit
+func test_project_BareRepo(t *testing.T, dir string, files map[string]string) {
+	work := dir + ".work"
+	defer os.RemoveAll(work)
+
+	test_prune_WriteFiles(work, files)
+	test_vcs_Git(t, work, "init", "-q")
+	test_vcs_Git(t, work, "add", ".")
+	test_vcs_Git(t, work, "commit", "-q", "-m", "first")
+	test_vcs_Git(t, filepath.Dir(work), "clone", "-q", "--bare", work, dir)
+}
@@ -0,0 +1,229 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_PROXY_DIR = ".tmp/proxy"
+)
+
+func Test_Proxy_Download(t *testing.T) {
+	server := test_proxy_Server()
+	defer server.Close()
+	defer os.RemoveAll(TEST_PROXY_DIR)
+
+	proxy := util.NewProxy(server.URL)
+
+	module, versions, err := proxy.FindModule("example.com/Foo/sub")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if module != "example.com/Foo" || len(versions) != 2 {
+		t.Errorf("Wrong module %s %v", module, versions)
+		return
+	}
+
+	version, err := proxy.Resolve(module, versions, "")
+	if err != nil || version != "v1.1.0" {
+		t.Errorf("Wrong latest version %s", version)
+		return
+	}
+	if version, _ = proxy.Resolve(module, versions, "master"); version != "v1.1.0" {
+		t.Errorf("Wrong branch version %s", version)
+	}
+
+	vendorDir := filepath.Join(TEST_PROXY_DIR, "src")
+	dir := filepath.Join(vendorDir, "example.com/Foo")
+	if err = proxy.Download(module, "v1.0.0", dir); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "sub", "sub.go")); string(content) != "package sub // v1.0.0\n" {
+		t.Errorf("Wrong unpacked file %q", content)
+	}
+
+	lock, err := util.NewLock(vendorDir, []util.Dependency{})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(lock.Packages) != 1 || lock.Packages[0].Vcs != util.PROXYVCS || lock.Packages[0].Revision != "v1.0.0" {
+		t.Errorf("Wrong locked packages %v", lock.Packages)
+		return
+	}
+
+	lock.Packages[0].Revision = "v1.1.0"
+	if err = lock.Restore(vendorDir, nil); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if module := util.ReadProxyModule(dir); module == nil || module.Version != "v1.1.0" {
+		t.Errorf("Wrong restored module %v", module)
+	}
+}
+
+func Test_Proxy_DownloadKeepsNested(t *testing.T) {
+	server := test_proxy_Server()
+	defer server.Close()
+	os.RemoveAll(TEST_PROXY_DIR)
+	defer os.RemoveAll(TEST_PROXY_DIR)
+
+	proxy := util.NewProxy(server.URL)
+	dir := filepath.Join(TEST_PROXY_DIR, "src/example.com/Foo")
+	if err := proxy.Download("example.com/Foo", "v1.0.0", dir); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	test_prune_WriteFiles(dir, map[string]string{
+		"old.go":                  "package foo\n",
+		"v2/foo.go":               "package foo\n",
+		"v2/" + util.PROXYMETA:    "path: example.com/Foo/v2\nversion: v2.0.0\n",
+		"vendored/lib.go":         "package vendored\n",
+		"vendored/.git/HEAD":      "ref: refs/heads/master\n",
+		"sub/nested/keep.go":      "package nested\n",
+		"sub/nested/.hg/requires": "store\n",
+	})
+
+	if err := proxy.Download("example.com/Foo", "v1.1.0", dir); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "sub", "sub.go")); string(content) != "package sub // v1.1.0\n" {
+		t.Errorf("Wrong unpacked file %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.go")); !os.IsNotExist(err) {
+		t.Error("File of replaced module must be removed")
+	}
+	for _, kept := range []string{"v2/foo.go", "vendored/lib.go", "sub/nested/keep.go"} {
+		if _, err := os.Stat(filepath.Join(dir, kept)); err != nil {
+			t.Errorf("Nested %s must be kept", kept)
+		}
+	}
+}
+
+func Test_Proxy_Outdated(t *testing.T) {
+	server := test_proxy_Server()
+	defer server.Close()
+	os.RemoveAll(TEST_PROXY_DIR)
+	defer os.RemoveAll(TEST_PROXY_DIR)
+
+	vendorDir := filepath.Join(TEST_PROXY_DIR, "src")
+	if err := util.NewProxy(server.URL).Download("example.com/Foo", "v1.0.0", filepath.Join(vendorDir, "example.com/Foo")); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	o, err := util.CheckOutdated(vendorDir, util.Dependency{Name: "example.com/Foo/sub", Version: "^1.0.0"}, false)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if o.Current != "v1.0.0" || o.Wanted != "v1.1.0" || o.Latest != "v1.1.0" || !o.Outdated {
+		t.Errorf("Wrong outdated report %v", o)
+	}
+
+	if o, _ = util.CheckOutdated(vendorDir, util.Dependency{Name: "example.com/Foo/sub", Version: "v1.0.0"}, false); o.Wanted != "v1.0.0" || o.Outdated {
+		t.Errorf("Pinned module must not be outdated %v", o)
+	}
+
+	hits := 0
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		handler.ServeHTTP(w, r)
+	})
+	if o, _ = util.CheckOutdated(vendorDir, util.Dependency{Name: "example.com/Foo/sub", Version: "^1.0.0"}, true); o.Current != "v1.0.0" || o.Wanted != "" || o.Latest != "" || o.Outdated {
+		t.Errorf("Offline module must be reported unknown %v", o)
+	}
+	if hits > 0 {
+		t.Error("Offline must not query proxy")
+	}
+}
+
+func Test_Proxy_Update(t *testing.T) {
+	server := test_proxy_Server()
+	defer server.Close()
+	test_project_SetUp()
+	defer test_project_TearDown()
+
+	ioutil.WriteFile(filepath.Join(TEST_PROJECT_CWD, "gopas.yml"), []byte("name: example.com/main\n"), 0644)
+
+	cases := [][2]string{
+		{"", "v1.1.0"},
+		{"master", "v1.1.0"},
+		{"^1.0.0", "^1.1.0"},
+		{"v1.1.0", ""},
+	}
+	for _, c := range cases {
+		// every update runs on its own, as gopas update does
+		project := util.NewProject(util.NewLogger(nil, nil), TEST_PROJECT_CWD)
+		project.Config = &util.Config{Proxy: server.URL}
+		version, err := project.Update(util.Dependency{Name: "example.com/Foo/sub", Version: c[0]})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if version != c[1] {
+			t.Errorf("Update of %q must record %q, got %q", c[0], c[1], version)
+		}
+	}
+}
+
+func Test_Proxy_FileUrl(t *testing.T) {
+	os.RemoveAll(TEST_PROXY_DIR)
+	defer os.RemoveAll(TEST_PROXY_DIR)
+
+	listDir := filepath.Join(TEST_PROXY_DIR, "proxy", "example.com", "!foo", "@v")
+	os.MkdirAll(listDir, 0755)
+	ioutil.WriteFile(filepath.Join(listDir, "list"), []byte("v1.0.0\n"), 0644)
+
+	abs, _ := filepath.Abs(filepath.Join(TEST_PROXY_DIR, "proxy"))
+	proxy := util.NewProxy("file://" + filepath.ToSlash(abs))
+
+	if module, _, err := proxy.FindModule("example.com/Foo/sub"); err != nil || module != "example.com/Foo" {
+		t.Errorf("Wrong module %s %v", module, err)
+	}
+	if _, _, err := proxy.FindModule("example.com/bar"); err == nil {
+		t.Error("Must fail on unknown module")
+	}
+}
+
+func test_proxy_Server() *httptest.Server {
+	zips := map[string][]byte{}
+	for _, version := range []string{"v1.0.0", "v1.1.0"} {
+		buf := &bytes.Buffer{}
+		w := zip.NewWriter(buf)
+		f, _ := w.Create("example.com/Foo@" + version + "/sub/sub.go")
+		f.Write([]byte("package sub // " + version + "\n"))
+		w.Close()
+		zips[version] = buf.Bytes()
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/example.com/!foo/")
+		switch {
+		case path == "@v/list":
+			w.Write([]byte("v1.0.0\nv1.1.0\n"))
+		case path == "@v/master.info":
+			w.Write([]byte(`{"Version":"v1.1.0","Time":"2020-01-01T00:00:00Z"}`))
+		case strings.HasSuffix(path, ".zip") && zips[strings.TrimSuffix(path[3:], ".zip")] != nil:
+			w.Write(zips[strings.TrimSuffix(path[3:], ".zip")])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
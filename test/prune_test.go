@@ -0,0 +1,166 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_PRUNE_DIR = ".tmp/prune"
+)
+
+func Test_Prune_DryRun(t *testing.T) {
+	test_prune_SetUp(t)
+	defer test_prune_TearDown()
+
+	pruned, _ := test_prune_Prune(t, true)
+	if len(pruned) != 1 || pruned[0].Name != "example.com/lib" {
+		t.Errorf("Wrong pruned packages %v", pruned)
+		return
+	}
+
+	expected := []string{
+		"example.com/lib/README.md",
+		"example.com/lib/lib.go",
+		"example.com/lib/unused/unused.go",
+		"example.com/lib/used/used_test.go",
+	}
+	if len(pruned[0].Files) != len(expected) {
+		t.Errorf("Wrong pruned files %v", pruned[0].Files)
+		return
+	}
+	for i, file := range pruned[0].Files {
+		if file != expected[i] {
+			t.Errorf("Wrong pruned file %s, expected %s", file, expected[i])
+		}
+		if _, err := os.Stat(filepath.Join(TEST_PRUNE_DIR, "vendor", file)); err != nil {
+			t.Errorf("Dry run must not remove %s", file)
+		}
+	}
+	if pruned[0].Bytes == 0 {
+		t.Error("Pruned bytes must be counted")
+	}
+}
+
+func Test_Prune_Remove(t *testing.T) {
+	test_prune_SetUp(t)
+	defer test_prune_TearDown()
+
+	pruned, sum := test_prune_Prune(t, false)
+	if len(pruned) != 1 {
+		t.Errorf("Wrong pruned packages %v", pruned)
+		return
+	}
+
+	lib := filepath.Join(TEST_PRUNE_DIR, "vendor/example.com/lib")
+	for _, file := range []string{"README.md", "lib.go", "unused", "used/used_test.go"} {
+		if _, err := os.Stat(filepath.Join(lib, file)); err == nil {
+			t.Errorf("%s must be removed", file)
+		}
+	}
+	for _, file := range []string{"LICENSE", "used/used.go", "used/data/a.txt", util.PRUNEMETA} {
+		if _, err := os.Stat(filepath.Join(lib, file)); err != nil {
+			t.Errorf("%s must be kept", file)
+		}
+	}
+
+	vendorDir := filepath.Join(TEST_PRUNE_DIR, "vendor")
+	lock, _ := util.NewLock(vendorDir, []util.Dependency{})
+	after, err := util.NewSum(vendorDir, lock)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err = sum.Verify(after); err != nil {
+		t.Errorf("Pruned package must keep its hash: %s", err.Error())
+	}
+
+	if err = util.VcsByName("git").Checkout(lib, "master"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if _, err = os.Stat(filepath.Join(lib, "README.md")); err != nil {
+		t.Error("Checkout of other revision must restore pruned files")
+	}
+	if util.ReadPruneMarker(lib) != nil {
+		t.Error("Checkout of other revision must remove prune marker")
+	}
+}
+
+func test_prune_Prune(t *testing.T, dryRun bool) ([]*util.PrunedPackage, *util.Sum) {
+	srcDir := filepath.Join(TEST_PRUNE_DIR, "src")
+	vendorDir := filepath.Join(TEST_PRUNE_DIR, "vendor")
+
+	lock, err := util.NewLock(vendorDir, []util.Dependency{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sum, err := util.NewSum(vendorDir, lock)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	graph, err := util.NewFullImportGraph("example.com/app", srcDir, vendorDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	pruned, err := util.Prune(vendorDir, graph, sum, dryRun)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return pruned, sum
+}
+
+func test_prune_SetUp(t *testing.T) {
+	test_prune_TearDown()
+
+	test_prune_WriteFiles(filepath.Join(TEST_PRUNE_DIR, "src/example.com/app"), map[string]string{
+		"main.go":     "package main\n\nimport _ \"example.com/lib/used\"\n\nfunc main() {}\n",
+		"app_test.go": "package main\n\nimport _ \"example.com/testonly\"\n",
+	})
+
+	lib := filepath.Join(TEST_PRUNE_DIR, "vendor/example.com/lib")
+	test_prune_WriteFiles(lib, map[string]string{
+		"LICENSE":           "Permission is hereby granted, free of charge",
+		"README.md":         "# lib",
+		"lib.go":            "package lib\n",
+		"unused/unused.go":  "package unused\n",
+		"used/used.go":      "package used\n\nimport _ \"embed\"\n\n//go:embed data/*.txt\nvar data string\n",
+		"used/used_test.go": "package used\n",
+		"used/data/a.txt":   "a",
+	})
+	test_vcs_Git(t, lib, "init", "-q")
+	test_vcs_Git(t, lib, "checkout", "-q", "-b", "master")
+	test_vcs_Git(t, lib, "remote", "add", "origin", "https://example.com/lib")
+	test_vcs_Git(t, lib, "add", ".")
+	test_vcs_Git(t, lib, "commit", "-q", "-m", "first")
+	test_vcs_Git(t, lib, "tag", "v1.0.0")
+	ioutil.WriteFile(filepath.Join(lib, "lib.go"), []byte("package lib\n\nconst Version = 2\n"), 0644)
+	test_vcs_Git(t, lib, "commit", "-q", "-a", "-m", "second")
+	test_vcs_Git(t, lib, "checkout", "-q", "v1.0.0")
+
+	testonly := filepath.Join(TEST_PRUNE_DIR, "vendor/example.com/testonly")
+	test_prune_WriteFiles(testonly, map[string]string{
+		"testonly.go": "package testonly\n",
+	})
+	test_vcs_Git(t, testonly, "init", "-q")
+	test_vcs_Git(t, testonly, "remote", "add", "origin", "https://example.com/testonly")
+	test_vcs_Git(t, testonly, "add", ".")
+	test_vcs_Git(t, testonly, "commit", "-q", "-m", "first")
+}
+
+func test_prune_WriteFiles(dir string, files map[string]string) {
+	for file, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(content), 0644)
+	}
+}
+
+func test_prune_TearDown() {
+	os.RemoveAll(TEST_PRUNE_DIR)
+}
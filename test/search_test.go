@@ -0,0 +1,134 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_SEARCH_DIR = ".tmp/search"
+)
+
+func Test_Search_IndexAndSearch(t *testing.T) {
+	test_search_SetUp()
+	defer test_search_TearDown()
+
+	index, err := util.ReadSearchIndex(filepath.Join(TEST_SEARCH_DIR, "cache"))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if err = index.AddDir(filepath.Join(TEST_SEARCH_DIR, "src")); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err = index.Write(filepath.Join(TEST_SEARCH_DIR, "cache")); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	index, _ = util.ReadSearchIndex(filepath.Join(TEST_SEARCH_DIR, "cache"))
+	pkg := index.Packages["example.com/backoff"]
+	if pkg == nil || pkg.Name != "backoff" || pkg.Synopsis != "Package backoff retries failing operations." {
+		t.Errorf("Wrong indexed package %v", pkg)
+		return
+	}
+	if len(pkg.Exported) != 3 || pkg.Exported[0] != "Policy" || pkg.Exported[1] != "Policy.Retry" || pkg.Exported[2] != "Retry" {
+		t.Errorf("Wrong exported identifiers %v", pkg.Exported)
+	}
+
+	results := index.Search("retry")
+	if len(results) != 2 || results[0].Path != "example.com/backoff" || results[1].Path != "example.com/http" {
+		t.Errorf("Wrong results %v", results)
+		return
+	}
+	if len(results[1].Matches) != 1 || results[1].Matches[0] != "RetryTransport" {
+		t.Errorf("Wrong matches %v", results[1].Matches)
+	}
+
+	if results = index.Search("retry policy"); len(results) != 1 || results[0].Path != "example.com/backoff" {
+		t.Errorf("Wrong results %v", results)
+	}
+}
+
+func Test_Search_AddLocked(t *testing.T) {
+	test_search_SetUp()
+	defer test_search_TearDown()
+
+	srcDir := filepath.Join(TEST_SEARCH_DIR, "src")
+	nested := filepath.Join(srcDir, "example.com/backoff/v2")
+	os.MkdirAll(nested, 0755)
+	ioutil.WriteFile(filepath.Join(nested, "v2.go"), []byte("package v2\n\nfunc Jitter() {}\n"), 0644)
+
+	lock := &util.Lock{Packages: []util.LockedPackage{
+		{Name: "example.com/backoff", Revision: "1"},
+		{Name: "example.com/backoff/v2", Revision: "1"},
+		{Name: "example.com/http", Revision: "1"},
+	}}
+	index, _ := util.ReadSearchIndex(filepath.Join(TEST_SEARCH_DIR, "cache"))
+	if err := index.AddLocked(srcDir, lock); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(index.Packages) != 3 || index.Packages["example.com/backoff/v2"] == nil {
+		t.Errorf("Wrong indexed packages %v", index.Packages)
+		return
+	}
+
+	// unchanged revisions are not parsed again
+	os.RemoveAll(filepath.Join(srcDir, "example.com/http"))
+	ioutil.WriteFile(filepath.Join(srcDir, "example.com/backoff/backoff.go"), []byte("package backoff\n\nfunc Backoff() {}\n"), 0644)
+	if err := index.AddLocked(srcDir, lock); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if index.Packages["example.com/http"] == nil || len(index.Packages["example.com/backoff"].Exported) != 3 {
+		t.Error("Package at indexed revision must not be parsed again")
+	}
+
+	lock.Packages[0].Revision = "2"
+	if err := index.AddLocked(srcDir, lock); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if pkg := index.Packages["example.com/backoff"]; len(pkg.Exported) != 1 || pkg.Exported[0] != "Backoff" {
+		t.Errorf("Package at new revision must be parsed again %v", pkg)
+	}
+	if index.Packages["example.com/backoff/v2"] == nil {
+		t.Error("Nested package must stay indexed")
+	}
+}
+
+func test_search_SetUp() {
+	os.RemoveAll(TEST_SEARCH_DIR)
+
+	files := map[string]string{
+		"src/example.com/backoff/backoff.go": `// Package backoff retries failing operations.
+package backoff
+
+type Policy struct{}
+
+func (p *Policy) Retry(f func() error) error { return f() }
+
+func Retry(f func() error) error { return f() }
+
+func wait() {}
+`,
+		"src/example.com/backoff/backoff_test.go": "package backoff\n\nfunc TestRetryHidden() {}\n",
+		"src/example.com/http/http.go":            "package http\n\nvar RetryTransport = 1\n",
+		"src/example.com/http/.git/ignored.go":    "package ignored\n\nfunc Retry() {}\n",
+	}
+	for file, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(TEST_SEARCH_DIR, file)), 0755)
+		ioutil.WriteFile(filepath.Join(TEST_SEARCH_DIR, file), []byte(content), 0644)
+	}
+}
+
+func test_search_TearDown() {
+	os.RemoveAll(TEST_SEARCH_DIR)
+}
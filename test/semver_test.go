@@ -0,0 +1,171 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+func Test_Semver_ParseVersion(t *testing.T) {
+	v, err := util.ParseVersion("v1.2.3-rc.1+build")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if v.Major != 1 || v.Minor != 2 || v.Patch != 3 || v.Pre != "rc.1" || v.Original != "v1.2.3-rc.1+build" {
+		t.Errorf("Wrong version %v", v)
+	}
+
+	for _, s := range []string{"master", "1.2", "1.x.3", "v1.2.3.4", ""} {
+		if _, err := util.ParseVersion(s); err == nil {
+			t.Errorf("Must fail to parse %s", s)
+		}
+	}
+}
+
+func Test_Semver_Compare(t *testing.T) {
+	ordered := []string{"0.9.0", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-beta", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.10.0"}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, _ := util.ParseVersion(ordered[i])
+		b, _ := util.ParseVersion(ordered[i+1])
+		if a.Compare(b) != -1 || b.Compare(a) != 1 {
+			t.Errorf("%s must be lower than %s", ordered[i], ordered[i+1])
+		}
+	}
+}
+
+func Test_Semver_IsConstraint(t *testing.T) {
+	for s, expected := range map[string]bool{
+		"^1.2":     true,
+		"~0.4.1":   true,
+		">=2.0 <3": true,
+		"1.x":      true,
+		"*":        true,
+		"v1.2.0":   false,
+		"master":   false,
+		"a1b2c3d":  false,
+		"":         false,
+	} {
+		if util.IsConstraint(s) != expected {
+			t.Errorf("IsConstraint(%q) must be %v", s, expected)
+		}
+	}
+}
+
+func Test_Semver_Check(t *testing.T) {
+	cases := map[string][]string{
+		"^1.2":         {"1.2.0", "1.9.9", "!2.0.0", "!1.1.9", "!2.0.0-rc.1"},
+		"^0.4":         {"0.4.0", "0.4.7", "!0.5.0"},
+		"^0.0.3":       {"0.0.3", "!0.0.4"},
+		"~0.4.1":       {"0.4.1", "0.4.9", "!0.5.0", "!0.4.0"},
+		"~1":           {"1.0.0", "1.9.0", "!2.0.0"},
+		">=2.0 <3":     {"2.0.0", "2.5.1", "!3.0.0", "!1.9.9"},
+		">= 2.0, < 3":  {},
+		"1.x || 3.1.x": {"1.0.0", "3.1.4", "!2.0.0", "!3.2.0"},
+		"<=1.2":        {"1.2.9", "!1.3.0"},
+		">1.2":         {"1.3.0", "!1.2.9"},
+		"=1.2.3":       {"1.2.3", "!1.2.4"},
+		"^1.0.0-beta":  {"1.0.0-beta", "1.0.0-rc.1", "1.0.0", "!1.0.1-rc.1"},
+	}
+
+	for s, versions := range cases {
+		constraint, err := util.ParseConstraint(s)
+		if len(versions) == 0 {
+			if err == nil {
+				t.Errorf("Must fail to parse %s", s)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Error(err.Error())
+			continue
+		}
+
+		for _, str := range versions {
+			expected := !strings.HasPrefix(str, "!")
+			v, _ := util.ParseVersion(strings.TrimPrefix(str, "!"))
+			if constraint.Check(v) != expected {
+				t.Errorf("%s check %s must be %v", s, v, expected)
+			}
+		}
+	}
+}
+
+func Test_Semver_SelectVersion(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.2.0", "v1.3.1", "v2.0.0", "v2.1.0-rc.1", "latest"}
+
+	version, err := util.SelectVersion("example.com/foo", tags, []util.Requirement{
+		{Version: "^1.2", Chain: []string{"app"}},
+		{Version: "<1.3", Chain: []string{"app", "example.com/bar"}},
+	})
+	if err != nil || version != "v1.2.0" {
+		t.Errorf("Wrong selected version %s %v", version, err)
+	}
+
+	version, _ = util.SelectVersion("example.com/foo", tags, []util.Requirement{
+		{Version: ">=1.0", Chain: []string{"app"}},
+	})
+	if version != "v2.0.0" {
+		t.Errorf("Must select highest version, got %s", version)
+	}
+
+	version, _ = util.SelectVersion("example.com/foo", tags, []util.Requirement{
+		{Version: "", Chain: []string{"app"}},
+	})
+	if version != "" {
+		t.Errorf("Must not select version without requirement, got %s", version)
+	}
+}
+
+func Test_Semver_SelectVersionConflict(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.2.0", "v2.0.0"}
+
+	_, err := util.SelectVersion("example.com/c", tags, []util.Requirement{
+		{Version: "^1.2", Chain: []string{"app", "example.com/a", "example.com/c=^1.2"}},
+		{Version: "", Chain: []string{"app", "example.com/d"}},
+		{Version: "^2.0", Chain: []string{"app", "example.com/b", "example.com/c=^2.0"}},
+	})
+
+	conflict, ok := err.(*util.ConflictError)
+	if !ok || len(conflict.Requirements) != 2 {
+		t.Errorf("Must fail with conflict, got %v", err)
+		return
+	}
+
+	msg := err.Error()
+	test_tool_AssertContains(t, msg, "app -> example.com/a -> example.com/c=^1.2 requires ^1.2")
+	test_tool_AssertContains(t, msg, "app -> example.com/b -> example.com/c=^2.0 requires ^2.0")
+
+	_, err = util.SelectVersion("example.com/c", tags, []util.Requirement{
+		{Version: "v1.0.0", Chain: []string{"app", "example.com/a"}},
+		{Version: "^1.2", Chain: []string{"app", "example.com/b"}},
+	})
+	if _, ok := err.(*util.ConflictError); !ok {
+		t.Errorf("Pinned version must conflict with range, got %v", err)
+	}
+
+	_, err = util.SelectVersion("example.com/c", tags, []util.Requirement{
+		{Version: "^3", Chain: []string{"app"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "no version of example.com/c matches ^3") {
+		t.Errorf("Must fail when nothing matches, got %v", err)
+	}
+}
+
+func Test_Semver_BumpConstraint(t *testing.T) {
+	for _, c := range [][3]string{
+		{"^1.2", "v1.4.0", "^1.4.0"},
+		{"~v0.4.1", "v0.4.3", "~v0.4.3"},
+		{">=2.0", "v2.1.0", ">=2.1.0"},
+		{">=2.0 <3", "v2.1.0", ">=2.0 <3"},
+		{"1.x || 2.x", "v2.1.0", "1.x || 2.x"},
+		{"^1.2", "master", "^1.2"},
+	} {
+		if bumped := util.BumpConstraint(c[0], c[1]); bumped != c[2] {
+			t.Errorf("Wrong bump of %s to %s: %s, expected %s", c[0], c[1], bumped, c[2])
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_SUM_DIR = ".tmp/sum"
+)
+
+func Test_Sum_HashDir(t *testing.T) {
+	dir := TEST_SUM_DIR
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	// expected hash computed with golang.org/x/mod/sumdb/dirhash.HashDir
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/foo\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sub", "sub.go"), []byte("package sub\n"), 0644)
+
+	hash, err := util.HashDir(dir, "example.com/foo@v1.0.0")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if hash != "h1:wGtDbnNxPblG098ALrF5ugxlOBGeifVCYjmNPDPOjLU=" {
+		t.Errorf("Wrong hash %s", hash)
+	}
+}
+
+func Test_Sum_Verify(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	vendorDir := filepath.Join(TEST_VCS_DIR, "src")
+	lock, err := util.NewLock(vendorDir, []util.Dependency{})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	sum, err := util.NewSum(vendorDir, lock)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err = sum.Write(TEST_VCS_DIR); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	recorded, err := util.ReadSum(TEST_VCS_DIR)
+	if err != nil || len(recorded.Entries) != 1 || recorded.Entries[0].Name != "example.com/foo" {
+		t.Errorf("Wrong recorded sum %v %v", recorded, err)
+		return
+	}
+
+	ioutil.WriteFile(filepath.Join(vendorDir, "example.com/foo/sub/sub.go"), []byte("package sub\n\n// edited\n"), 0644)
+	modified, _ := util.NewSum(vendorDir, lock)
+	if err = recorded.Verify(modified); err == nil {
+		t.Error("Must refuse modified vendored file")
+	}
+
+	modified.Entries[0].Revision = "other"
+	if err = recorded.Verify(modified); err != nil {
+		t.Error("Must trust revision not recorded yet")
+	}
+}
+
+func Test_Sum_NestedRoots(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	vendorDir := filepath.Join(TEST_VCS_DIR, "src")
+	nested := filepath.Join(vendorDir, "example.com/foo/v2")
+	test_prune_WriteFiles(nested, map[string]string{
+		"v2.go":        "package v2\n",
+		util.PROXYMETA: "path: example.com/foo/v2\nversion: v2.0.0\nproxy: file:///proxy\n",
+	})
+
+	lock, _ := util.NewLock(vendorDir, []util.Dependency{})
+	recorded, err := util.NewSum(vendorDir, lock)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(recorded.Entries) != 2 || recorded.Entries[1].Name != "example.com/foo/v2" {
+		t.Errorf("Nested module must have its own entry, got %v", recorded.Entries)
+		return
+	}
+
+	// upgrading nested module leaves hash of repository around it alone
+	test_prune_WriteFiles(nested, map[string]string{
+		"v2.go":        "package v2\n\n// upgraded\n",
+		util.PROXYMETA: "path: example.com/foo/v2\nversion: v2.1.0\nproxy: file:///proxy\n",
+	})
+	lock, _ = util.NewLock(vendorDir, []util.Dependency{})
+	upgraded, err := util.NewSum(vendorDir, lock)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if upgraded.Entries[0].Hash != recorded.Entries[0].Hash {
+		t.Error("Hash of parent must not include nested module")
+	}
+	if err = recorded.Verify(upgraded); err != nil {
+		t.Error(err.Error())
+	}
+}
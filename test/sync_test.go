@@ -0,0 +1,81 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_SYNC_DIR = ".tmp/sync"
+)
+
+func Test_Sync_Incremental(t *testing.T) {
+	os.RemoveAll(TEST_SYNC_DIR)
+	defer os.RemoveAll(TEST_SYNC_DIR)
+
+	source, dest := filepath.Join(TEST_SYNC_DIR, "source"), filepath.Join(TEST_SYNC_DIR, "dest")
+	test_prune_WriteFiles(source, map[string]string{
+		"main.go":          "package main\n",
+		"lib/lib.go":       "package lib\n",
+		"lib/lib.log":      "log",
+		"fixtures/big.txt": "fixture",
+		"removed.go":       "package main\n",
+	})
+
+	ignore := util.NewIgnore(source, []string{"*.log", "fixtures/"})
+	if err := util.SyncDir(source, dest, ignore); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	test_sync_AssertFiles(t, dest, []string{"lib/lib.go", "main.go", "removed.go"})
+
+	// same size and time means unchanged, so it is not copied again
+	ioutil.WriteFile(filepath.Join(dest, "main.go"), []byte("package xxxx\n"), 0644)
+	info, _ := os.Stat(filepath.Join(source, "main.go"))
+	os.Chtimes(filepath.Join(dest, "main.go"), info.ModTime(), info.ModTime())
+
+	later := time.Now().Add(time.Minute)
+	ioutil.WriteFile(filepath.Join(source, "lib/lib.go"), []byte("package lib\n\n// changed\n"), 0644)
+	os.Chtimes(filepath.Join(source, "lib/lib.go"), later, later)
+	os.Remove(filepath.Join(source, "removed.go"))
+	ioutil.WriteFile(filepath.Join(dest, "stale.log"), []byte("stale"), 0644)
+
+	if err := util.SyncDir(source, dest, ignore); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	test_sync_AssertFiles(t, dest, []string{"lib/lib.go", "main.go"})
+
+	if content, _ := ioutil.ReadFile(filepath.Join(dest, "main.go")); string(content) != "package xxxx\n" {
+		t.Error("Unchanged file must not be copied")
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(dest, "lib/lib.go")); string(content) != "package lib\n\n// changed\n" {
+		t.Error("Changed file must be copied")
+	}
+}
+
+func test_sync_AssertFiles(t *testing.T, dir string, expected []string) {
+	files := []string{}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+
+	if len(files) != len(expected) {
+		t.Errorf("Wrong files %v, expected %v", files, expected)
+		return
+	}
+	for i, file := range files {
+		if file != expected[i] {
+			t.Errorf("Wrong file %s, expected %s", file, expected[i])
+		}
+	}
+}
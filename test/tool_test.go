@@ -5,10 +5,13 @@ package test
  */
 import (
 	"bytes"
+	"errors"
+	"flag"
 	"strings"
 	"testing"
 
 	"github.com/reekoheek/gopas/util"
+	"gopkg.in/urfave/cli.v2"
 )
 
 type test_tool_ProjectMock struct {
@@ -18,7 +21,14 @@ type test_tool_ProjectMock struct {
 	isBuilt         bool
 	isRan           bool
 	isTested        bool
+	isLocked        bool
+	isVerified      bool
+	updated         []string
+	recorded        []string
+	scanned         int
 	willReturnError bool
+	isRestoring     bool
+	licensePolicy   util.LicensePolicy
 }
 
 func (p *test_tool_ProjectMock) Bootstrap() error {
@@ -26,10 +36,14 @@ func (p *test_tool_ProjectMock) Bootstrap() error {
 	return nil
 }
 
+func (p *test_tool_ProjectMock) IsModule() bool {
+	return false
+}
+
 func (p *test_tool_ProjectMock) Dependencies() []util.Dependency {
 	return []util.Dependency{
-		util.Dependency{"github.com/reekoheek/foo", ""},
-		util.Dependency{"github.com/reekoheek/bar", ""},
+		{Name: "github.com/reekoheek/foo"},
+		{Name: "github.com/reekoheek/bar"},
 	}
 }
 
@@ -38,10 +52,113 @@ func (p *test_tool_ProjectMock) Clean() error {
 	return nil
 }
 
-func (p *test_tool_ProjectMock) Install(dependency util.Dependency) error {
+func (p *test_tool_ProjectMock) Get(dependency util.Dependency) error {
+	if p.willReturnError {
+		return errors.New("exit status 1: package " + dependency.Name + ": not found\nsecond line")
+	}
+	return nil
+}
+
+func (p *test_tool_ProjectMock) Lock() (*util.Lock, error) {
+	if !p.isRestoring {
+		return nil, nil
+	}
+	return &util.Lock{Dependencies: []string{"github.com/reekoheek/foo", "github.com/reekoheek/bar"}}, nil
+}
+
+func (p *test_tool_ProjectMock) Restore(lock *util.Lock) error {
+	if p.willReturnError {
+		return &util.RestoreError{
+			Packages: []util.LockedPackage{{Name: "github.com/reekoheek/foo", Revision: strings.Repeat("f", 40)}},
+			Errors:   []error{errors.New("revision not found")},
+			Total:    2,
+		}
+	}
+	return nil
+}
+
+func (p *test_tool_ProjectMock) Scan() (*util.Lock, error) {
+	p.scanned++
+	return &util.Lock{
+		Packages: []util.LockedPackage{
+			{Name: "github.com/reekoheek/foo", Revision: strings.Repeat(string(rune('0'+p.scanned)), 40)},
+		},
+	}, nil
+}
+
+func (p *test_tool_ProjectMock) Update(dependency util.Dependency) (string, error) {
+	p.updated = append(p.updated, dependency.Name)
+	if dependency.Name == "github.com/reekoheek/foo" {
+		return "^1.2.0", nil
+	}
+	return "", nil
+}
+
+func (p *test_tool_ProjectMock) SetDependencyVersion(name string, version string) error {
+	p.recorded = append(p.recorded, name+"="+version)
+	return nil
+}
+
+func (p *test_tool_ProjectMock) Outdated(dependency util.Dependency) (*util.Outdated, error) {
+	if dependency.Name == "github.com/reekoheek/bar" {
+		return nil, errors.New("revision not found")
+	}
+	return &util.Outdated{Name: dependency.Name, Current: "v1.0.0", Wanted: "v1.1.0", Latest: "v1.1.0", Outdated: true}, nil
+}
+
+func (p *test_tool_ProjectMock) WriteLock() error {
+	p.isLocked = true
+	return nil
+}
+
+func (p *test_tool_ProjectMock) Verify() error {
+	p.isVerified = true
 	return nil
 }
 
+func (p *test_tool_ProjectMock) Licenses() ([]*util.License, error) {
+	return []*util.License{
+		{Name: "github.com/reekoheek/bar", Revision: strings.Repeat("b", 40), Type: "GPL", File: "COPYING"},
+		{Name: "github.com/reekoheek/foo", Revision: strings.Repeat("f", 40), Type: "MIT", File: "LICENSE"},
+	}, nil
+}
+
+func (p *test_tool_ProjectMock) Audit(advisories string) ([]*util.AuditFinding, []string, error) {
+	return []*util.AuditFinding{
+		{Name: "github.com/reekoheek/foo", Version: "v1.0.0", ID: "GO-0001", Severity: "moderate", Fixed: []string{"1.0.1"}},
+	}, []string{}, nil
+}
+
+func (p *test_tool_ProjectMock) Prune(dryRun bool) ([]*util.PrunedPackage, error) {
+	return []*util.PrunedPackage{
+		{Name: "github.com/reekoheek/foo", Files: []string{"github.com/reekoheek/foo/foo_test.go", "github.com/reekoheek/foo/logo.png"}, Bytes: 3 * 1024},
+	}, nil
+}
+
+func (p *test_tool_ProjectMock) Ignore() (*util.Ignore, error) {
+	return util.NewIgnore(".", []string{}), nil
+}
+
+func (p *test_tool_ProjectMock) LicensePolicy() util.LicensePolicy {
+	return p.licensePolicy
+}
+
+func (p *test_tool_ProjectMock) Index() error {
+	return nil
+}
+
+func (p *test_tool_ProjectMock) Graph() (*util.ImportGraph, error) {
+	return &util.ImportGraph{
+		Name:    "foo",
+		Imports: map[string][]string{},
+		Dirs:    map[string]string{},
+	}, nil
+}
+
+func (p *test_tool_ProjectMock) FullGraph() (*util.ImportGraph, error) {
+	return p.Graph()
+}
+
 func (p *test_tool_ProjectMock) Name() string {
 	return "foo"
 }
@@ -66,29 +183,21 @@ func test_tool_New() *util.Tool {
 		isBootstrapped: false,
 	}
 
-	tool := &util.Tool{
-		Project: project,
-	}
-
-	logger := (&util.Logger{
-		Out: bytes.NewBuffer([]byte{}),
-		Err: bytes.NewBuffer([]byte{}),
-	}).Construct()
-	tool.Construct(logger)
+	logger := util.NewLogger(bytes.NewBuffer([]byte{}), bytes.NewBuffer([]byte{}))
+	tool, _ := util.NewTool(logger, project)
 	return tool
 }
 
 func test_tool_AssertContains(t *testing.T, str string, s string) bool {
 	if !strings.Contains(str, s) {
-		t.Error("String does not contain %s", s)
+		t.Errorf("String does not contain %s", s)
 		return false
 	}
 	return true
 }
 
 func Test_Tool_BootstrapWithoutProject(t *testing.T) {
-	tool := &util.Tool{}
-	if _, err := tool.Construct(nil); err == nil || err.Error() != "Project is undefined" {
+	if _, err := util.NewTool(nil, nil); err == nil || err.Error() != "Project is undefined" {
 		t.Error("Construct failed")
 	}
 }
@@ -128,6 +237,58 @@ func Test_Tool_DoBuild(t *testing.T) {
 	test_tool_AssertContains(t, out, "Building")
 }
 
+func Test_Tool_DoBuildDeniedLicense(t *testing.T) {
+	tool := test_tool_New()
+	project := tool.Project.(*test_tool_ProjectMock)
+	project.licensePolicy = util.LicensePolicy{Deny: []string{"gpl"}}
+
+	err := tool.DoBuild(nil)
+	if err == nil || !strings.Contains(err.Error(), "github.com/reekoheek/bar: GPL") {
+		t.Errorf("Wrong error %v", err)
+	}
+	if project.isBuilt {
+		t.Error("Project must not be built with denied license")
+	}
+}
+
+func Test_Tool_DoLicenses(t *testing.T) {
+	tool := test_tool_New()
+	tool.Project.(*test_tool_ProjectMock).licensePolicy = util.LicensePolicy{Allow: []string{"MIT"}}
+
+	if err := tool.DoLicenses(nil); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	out := tool.Out.(*bytes.Buffer).String()
+	test_tool_AssertContains(t, out, "github.com/reekoheek/bar  bbbbbbbbbbbb  GPL      COPYING  denied")
+	test_tool_AssertContains(t, out, "github.com/reekoheek/foo  ffffffffffff  MIT      LICENSE")
+}
+
+func Test_Tool_DoAudit(t *testing.T) {
+	tool := test_tool_New()
+
+	if err := tool.DoAudit(nil); err == nil || err.Error() != "1 advisories at or above low severity" {
+		t.Errorf("Wrong error %v", err)
+	}
+
+	out := tool.Out.(*bytes.Buffer).String()
+	test_tool_AssertContains(t, out, "github.com/reekoheek/foo  v1.0.0   GO-0001   moderate  1.0.1")
+}
+
+func Test_Tool_DoVendorPrune(t *testing.T) {
+	tool := test_tool_New()
+
+	if err := tool.DoVendorPrune(nil); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	out := tool.Out.(*bytes.Buffer).String()
+	test_tool_AssertContains(t, out, "github.com/reekoheek/foo: 2 files, 3.0 KiB")
+	test_tool_AssertContains(t, out, "Removed 2 files, saved 3.0 KiB")
+}
+
 func Test_Tool_DoInstall(t *testing.T) {
 	tool := test_tool_New()
 	tool.DoInstall(nil)
@@ -135,6 +296,69 @@ func Test_Tool_DoInstall(t *testing.T) {
 	out := tool.Out.(*bytes.Buffer).String()
 	test_tool_AssertContains(t, out, "github.com/reekoheek/foo")
 	test_tool_AssertContains(t, out, "github.com/reekoheek/bar")
+
+	if !tool.Project.(*test_tool_ProjectMock).isVerified {
+		t.Error("Sum not verified yet")
+	}
+
+	if !tool.Project.(*test_tool_ProjectMock).isLocked {
+		t.Error("Lock not written yet")
+	}
+}
+
+func Test_Tool_DoInstallFailure(t *testing.T) {
+	tool := test_tool_New()
+	project := tool.Project.(*test_tool_ProjectMock)
+	project.willReturnError = true
+
+	if err := tool.DoInstall(nil); err == nil || err.Error() != "2 of 2 dependencies failed" {
+		t.Errorf("Wrong error %v", err)
+	}
+
+	out := tool.Err.(*bytes.Buffer).String()
+	test_tool_AssertContains(t, out, "DEPENDENCY")
+	test_tool_AssertContains(t, out, "package github.com/reekoheek/foo: not found")
+	test_tool_AssertContains(t, out, "    second line")
+
+	if project.isLocked {
+		t.Error("Lock must not be written after failure")
+	}
+}
+
+func Test_Tool_DoInstallRestoreFailure(t *testing.T) {
+	tool := test_tool_New()
+	project := tool.Project.(*test_tool_ProjectMock)
+	project.isRestoring = true
+	project.willReturnError = true
+
+	if err := tool.DoInstall(nil); err == nil || err.Error() != "1 of 2 locked packages failed to restore" {
+		t.Errorf("Wrong error %v", err)
+	}
+	test_tool_AssertContains(t, tool.Err.(*bytes.Buffer).String(), "github.com/reekoheek/foo  ffffffffffffffffffffffffffffffffffffffff  revision not found")
+	if project.isVerified {
+		t.Error("Sum must not be verified after failure")
+	}
+
+	set := flag.NewFlagSet("install", flag.ContinueOnError)
+	set.Bool("keep-going", true, "")
+	set.Int("jobs", 1, "")
+	tool.DoInstall(cli.NewContext(&cli.App{}, set, nil))
+	if !project.isVerified {
+		t.Error("Keep going must go on after failed restore")
+	}
+}
+
+func Test_Tool_DoOutdated(t *testing.T) {
+	tool := test_tool_New()
+	if err := tool.DoOutdated(nil); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	out := tool.Out.(*bytes.Buffer).String()
+	test_tool_AssertContains(t, out, "github.com/reekoheek/foo  -        v1.0.0   v1.1.0  v1.1.0  *")
+	test_tool_AssertContains(t, out, "github.com/reekoheek/bar  -        -        -       -")
+	test_tool_AssertContains(t, tool.Err.(*bytes.Buffer).String(), "github.com/reekoheek/bar: revision not found")
 }
 
 func Test_Tool_DoRun(t *testing.T) {
@@ -160,3 +384,39 @@ func Test_Tool_DoTest(t *testing.T) {
 	out := tool.Out.(*bytes.Buffer).String()
 	test_tool_AssertContains(t, out, "Testing")
 }
+
+func Test_Tool_DoAddInvalidName(t *testing.T) {
+	for _, arg := range []string{"", " \t", "=v1.0.0", "example.com/foo bar"} {
+		set := flag.NewFlagSet("add", flag.ContinueOnError)
+		set.Parse([]string{arg})
+
+		tool := test_tool_New()
+		if err := tool.DoAdd(cli.NewContext(&cli.App{}, set, nil)); err == nil {
+			t.Errorf("Must refuse package name %q", arg)
+		}
+		if project := tool.Project.(*test_tool_ProjectMock); project.isLocked {
+			t.Error("Lock must not be written")
+		}
+	}
+}
+
+func Test_Tool_DoUpdate(t *testing.T) {
+	tool := test_tool_New()
+	if err := tool.DoUpdate(nil); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	project := tool.Project.(*test_tool_ProjectMock)
+	if len(project.updated) != 2 || !project.isLocked {
+		t.Error("Dependencies not updated yet")
+		return
+	}
+	if len(project.recorded) != 1 || project.recorded[0] != "github.com/reekoheek/foo=^1.2.0" {
+		t.Errorf("Wrong versions recorded %v", project.recorded)
+	}
+
+	out := tool.Out.(*bytes.Buffer).String()
+	test_tool_AssertContains(t, out, "github.com/reekoheek/foo  111111111111  222222222222")
+	test_tool_AssertContains(t, out, "github.com/reekoheek/bar  -             -")
+}
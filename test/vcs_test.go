@@ -0,0 +1,364 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reekoheek/gopas/util"
+)
+
+const (
+	TEST_VCS_DIR = ".tmp/vcs"
+)
+
+func Test_Vcs_FindRepoRoot(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	base := filepath.Join(TEST_VCS_DIR, "src")
+	root, vcs, err := util.FindRepoRoot(base, filepath.Join(base, "example.com/foo/sub"))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if root != filepath.Join(base, "example.com/foo") || vcs != util.VcsGit {
+		t.Errorf("Wrong repository root %s", root)
+	}
+
+	if _, _, err = util.FindRepoRoot(base, filepath.Join(base, "example.com")); err == nil {
+		t.Error("Must fail outside repository")
+	}
+}
+
+func Test_Vcs_Checkout(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	dir := filepath.Join(TEST_VCS_DIR, "src/example.com/foo")
+	tagged := test_vcs_Git(t, dir, "rev-parse", "v1.0.0")
+
+	if err := util.VcsGit.Checkout(dir, "v1.0.0"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if rev, _ := util.VcsGit.Revision(dir); rev != tagged {
+		t.Errorf("Revision %s does not match tag %s", rev, tagged)
+	}
+
+	if err := util.VcsGit.Checkout(dir, "v9.9.9"); err == nil {
+		t.Error("Must fail on unknown revision")
+	}
+}
+
+func Test_Vcs_ForRemote(t *testing.T) {
+	for remote, expected := range map[string]*util.Vcs{
+		"https://github.com/foo/bar":          util.VcsGit,
+		"git@host:private.git":                util.VcsGit,
+		"/srv/repos/foo.git":                  util.VcsGit,
+		"git://example.com/foo":               util.VcsGit,
+		"ssh://hg@bitbucket.org/foo/bar":      util.VcsHg,
+		"https://hg.example.com/foo":          util.VcsHg,
+		"bzr+ssh://example.com/foo":           util.VcsBzr,
+		"lp:foo":                              util.VcsBzr,
+		"https://code.launchpad.net/~me/foo":  util.VcsBzr,
+		"svn://example.com/foo":               util.VcsSvn,
+		"https://example.com/svn/foo/trunk":   util.VcsSvn,
+		"file:///srv/repos/foo.hg":            util.VcsHg,
+		"https://example.com/me/undetectable": nil,
+	} {
+		if vcs := util.VcsForRemote(remote); vcs != expected {
+			t.Errorf("Wrong vcs %v of %s", vcs, remote)
+		}
+	}
+}
+
+func Test_Vcs_CreateFromFileUrl(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	abs, _ := filepath.Abs(filepath.Join(TEST_VCS_DIR, "origin"))
+	remote := "file://" + filepath.ToSlash(abs)
+	dir := filepath.Join(TEST_VCS_DIR, "src/example.com/bar")
+
+	vcs := util.VcsByName("git")
+	if err := vcs.Create(remote, dir); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if tags, _ := vcs.Tags(dir); len(tags) != 1 || tags[0] != "v1.0.0" {
+		t.Errorf("Wrong tags %v", tags)
+	}
+
+	if err := vcs.Checkout(dir, "v1.0.0"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if tag := vcs.Tag(dir); tag != "v1.0.0" {
+		t.Errorf("Wrong tag %s", tag)
+	}
+	if r, _ := vcs.Remote(dir); r != remote {
+		t.Errorf("Wrong remote %s", r)
+	}
+}
+
+func test_vcs_SetUp(t *testing.T) {
+	os.RemoveAll(TEST_VCS_DIR)
+
+	origin := filepath.Join(TEST_VCS_DIR, "origin")
+	os.MkdirAll(filepath.Join(origin, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(origin, "sub", "sub.go"), []byte("package sub\n"), 0644)
+	test_vcs_Git(t, origin, "init", "-q")
+	test_vcs_Git(t, origin, "add", ".")
+	test_vcs_Git(t, origin, "commit", "-q", "-m", "first")
+	test_vcs_Git(t, origin, "tag", "v1.0.0")
+	ioutil.WriteFile(filepath.Join(origin, "sub", "sub.go"), []byte("package sub\n\n// second\n"), 0644)
+	test_vcs_Git(t, origin, "commit", "-q", "-a", "-m", "second")
+
+	abs, _ := filepath.Abs(origin)
+	test_vcs_Git(t, TEST_VCS_DIR, "clone", "-q", abs, "src/example.com/foo")
+}
+
+func test_vcs_TearDown() {
+	os.RemoveAll(TEST_VCS_DIR)
+}
+
+func test_vcs_Git(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=gopas", "-c", "user.email=gopas@localhost"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git %s: %s", args, err.Error())
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func Test_Vcs_CheckoutUpstream(t *testing.T) {
+	test_vcs_SetUp(t)
+	defer test_vcs_TearDown()
+
+	dir := filepath.Join(TEST_VCS_DIR, "src/example.com/foo")
+	origin := filepath.Join(TEST_VCS_DIR, "origin")
+	test_vcs_Git(t, dir, "checkout", "-q", "v1.0.0")
+
+	ioutil.WriteFile(filepath.Join(origin, "sub", "sub.go"), []byte("package sub\n\n// third\n"), 0644)
+	test_vcs_Git(t, origin, "commit", "-q", "-a", "-m", "third")
+	head := test_vcs_Git(t, origin, "rev-parse", "HEAD")
+
+	if err := util.VcsGit.Download(dir); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if err := util.VcsGit.CheckoutUpstream(dir, ""); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if rev, _ := util.VcsGit.Revision(dir); rev != head {
+		t.Errorf("Revision %s is not upstream head %s", rev, head)
+	}
+}
+
+func Test_Vcs_Hg(t *testing.T) {
+	test_vcs_Require(t, "hg")
+	os.RemoveAll(TEST_VCS_DIR)
+	defer test_vcs_TearDown()
+
+	origin := filepath.Join(TEST_VCS_DIR, "origin")
+	test_prune_WriteFiles(origin, map[string]string{"sub/sub.go": "package sub\n"})
+	test_vcs_Run(t, origin, "hg", "init")
+	test_vcs_Run(t, origin, "hg", "add", "-q")
+	test_vcs_Run(t, origin, "hg", "commit", "-q", "-m", "first")
+	test_vcs_Run(t, origin, "hg", "tag", "v1.0.0")
+	test_prune_WriteFiles(origin, map[string]string{"sub/sub.go": "package sub\n\n// second\n"})
+	test_vcs_Run(t, origin, "hg", "commit", "-q", "-m", "second")
+
+	test_vcs_Templates(t, util.VcsHg, origin, func() string {
+		test_prune_WriteFiles(origin, map[string]string{"sub/sub.go": "package sub\n\n// third\n"})
+		test_vcs_Run(t, origin, "hg", "commit", "-q", "-m", "third")
+		test_vcs_Run(t, origin, "hg", "tag", "v1.1.0")
+		return test_vcs_Run(t, origin, "hg", "log", "-r", "v1.1.0", "--template", "{node}")
+	})
+}
+
+func Test_Vcs_Bzr(t *testing.T) {
+	test_vcs_Require(t, "bzr")
+	os.RemoveAll(TEST_VCS_DIR)
+	defer test_vcs_TearDown()
+
+	origin := filepath.Join(TEST_VCS_DIR, "origin")
+	test_prune_WriteFiles(origin, map[string]string{"sub/sub.go": "package sub\n"})
+	test_vcs_Run(t, origin, "bzr", "init", "-q")
+	test_vcs_Run(t, origin, "bzr", "add", "-q")
+	test_vcs_Run(t, origin, "bzr", "commit", "-q", "-m", "first")
+	test_vcs_Run(t, origin, "bzr", "tag", "-q", "v1.0.0")
+	test_prune_WriteFiles(origin, map[string]string{"sub/sub.go": "package sub\n\n// second\n"})
+	test_vcs_Run(t, origin, "bzr", "commit", "-q", "-m", "second")
+
+	test_vcs_Templates(t, util.VcsBzr, origin, func() string {
+		test_prune_WriteFiles(origin, map[string]string{"sub/sub.go": "package sub\n\n// third\n"})
+		test_vcs_Run(t, origin, "bzr", "commit", "-q", "-m", "third")
+		test_vcs_Run(t, origin, "bzr", "tag", "-q", "v1.1.0")
+		return test_vcs_Run(t, origin, "bzr", "version-info", "--custom", "--template={revision_id}")
+	})
+}
+
+func Test_Vcs_Svn(t *testing.T) {
+	test_vcs_Require(t, "svn")
+	test_vcs_Require(t, "svnadmin")
+	os.RemoveAll(TEST_VCS_DIR)
+	defer test_vcs_TearDown()
+
+	repo, _ := filepath.Abs(filepath.Join(TEST_VCS_DIR, "repo"))
+	url := "file://" + filepath.ToSlash(repo)
+	work := filepath.Join(TEST_VCS_DIR, "work")
+	os.MkdirAll(TEST_VCS_DIR, 0755)
+	test_vcs_Run(t, TEST_VCS_DIR, "svnadmin", "create", repo)
+	test_vcs_Run(t, TEST_VCS_DIR, "svn", "checkout", "-q", url, "work")
+	test_prune_WriteFiles(work, map[string]string{"sub/sub.go": "package sub\n"})
+	test_vcs_Run(t, work, "svn", "add", "-q", "sub")
+	test_vcs_Run(t, work, "svn", "commit", "-q", "-m", "first")
+	test_prune_WriteFiles(work, map[string]string{"sub/sub.go": "package sub\n\n// second\n"})
+	test_vcs_Run(t, work, "svn", "commit", "-q", "-m", "second")
+
+	dir := filepath.Join(TEST_VCS_DIR, "src/example.com/foo")
+	if err := util.VcsSvn.Create(url, dir); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if rev, _ := util.VcsSvn.Revision(dir); rev != "2" {
+		t.Errorf("Wrong revision %s", rev)
+	}
+	if remote, _ := util.VcsSvn.Remote(dir); remote != url {
+		t.Errorf("Wrong remote %s", remote)
+	}
+
+	if err := util.VcsSvn.Checkout(dir, "1"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if rev, _ := util.VcsSvn.Revision(dir); rev != "1" {
+		t.Errorf("Revision %s is not checked out revision 1", rev)
+	}
+
+	test_prune_WriteFiles(work, map[string]string{"sub/sub.go": "package sub\n\n// third\n"})
+	test_vcs_Run(t, work, "svn", "commit", "-q", "-m", "third")
+	if rev, err := util.VcsSvn.Resolve(dir, ""); err != nil || rev != "3" {
+		t.Errorf("Default ref must resolve to newest revision, got %s %v", rev, err)
+	}
+	if err := util.VcsSvn.CheckoutUpstream(dir, ""); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if rev, _ := util.VcsSvn.Revision(dir); rev != "3" {
+		t.Errorf("Revision %s is not upstream head 3", rev)
+	}
+
+	test_vcs_AssertUnprune(t, util.VcsSvn, dir, "2")
+}
+
+func Test_Vcs_SvnConstraint(t *testing.T) {
+	requirements := []util.Requirement{{Version: "^1.0.0", Chain: []string{"example.com/app"}}}
+	_, err := util.VcsSvn.SelectVersion(TEST_VCS_DIR, "example.com/foo", requirements)
+	if err == nil || err.Error() != "version constraints not supported for svn" {
+		t.Errorf("Wrong error %v", err)
+	}
+}
+
+// check templates of vcs against origin holding tag v1.0.0 and a commit
+// after it, release adds tag v1.1.0 to origin returning its revision
+func test_vcs_Templates(t *testing.T, vcs *util.Vcs, origin string, release func() string) {
+	abs, _ := filepath.Abs(origin)
+	dir := filepath.Join(TEST_VCS_DIR, "src/example.com/foo")
+	if err := vcs.Create(abs, dir); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if tags, err := vcs.Tags(dir); err != nil || len(tags) != 1 || tags[0] != "v1.0.0" {
+		t.Errorf("Wrong tags %v %v", tags, err)
+	}
+
+	tagged, err := vcs.Resolve(dir, "v1.0.0")
+	if err != nil || tagged == "" {
+		t.Errorf("Tag must resolve %v", err)
+		return
+	}
+	if err = vcs.Checkout(dir, "v1.0.0"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if rev, _ := vcs.Revision(dir); rev != tagged {
+		t.Errorf("Revision %s does not match tag %s", rev, tagged)
+	}
+	if tag := vcs.Tag(dir); tag != "v1.0.0" {
+		t.Errorf("Wrong tag %s", tag)
+	}
+
+	// unknown locally, checkout must download it
+	released := release()
+	if err = vcs.Checkout(dir, "v1.1.0"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if rev, _ := vcs.Revision(dir); rev != released {
+		t.Errorf("Revision %s does not match downloaded tag %s", rev, released)
+	}
+
+	if err = vcs.CheckoutUpstream(dir, ""); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if rev, err := vcs.Resolve(dir, ""); err != nil || rev == "" {
+		t.Errorf("Default ref must resolve %v", err)
+	}
+
+	if err = vcs.Checkout(dir, "v9.9.9"); err == nil {
+		t.Error("Must fail on unknown revision")
+	}
+
+	test_vcs_AssertUnprune(t, vcs, dir, "v1.0.0")
+}
+
+// check that checkout of ref other than pruned revision brings back files
+// prune removed
+func test_vcs_AssertUnprune(t *testing.T, vcs *util.Vcs, dir string, ref string) {
+	os.Remove(filepath.Join(dir, "sub/sub.go"))
+	ioutil.WriteFile(filepath.Join(dir, util.PRUNEMETA), []byte("revision: pruned\n"), 0644)
+
+	if err := vcs.Checkout(dir, ref); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub/sub.go")); err != nil {
+		t.Error("Checkout of other revision must restore pruned files")
+	}
+	if util.ReadPruneMarker(dir) != nil {
+		t.Error("Checkout of other revision must remove prune marker")
+	}
+}
+
+func test_vcs_Require(t *testing.T, name string) {
+	if _, err := exec.LookPath(name); err != nil {
+		t.Skipf("%s not installed", name)
+	}
+}
+
+func test_vcs_Run(t *testing.T, dir string, name string, args ...string) string {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "HGUSER=gopas <gopas@localhost>", "BZR_EMAIL=gopas <gopas@localhost>", "HGPLAIN=1")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%s %s: %s", name, args, err.Error())
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,354 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Advisory types, the part of OSV format gopas matches vendored packages
+ * against
+ */
+type (
+	OsvEvent struct {
+		Introduced   string `json:"introduced"`
+		Fixed        string `json:"fixed"`
+		LastAffected string `json:"last_affected"`
+	}
+
+	OsvRange struct {
+		Type   string     `json:"type"`
+		Events []OsvEvent `json:"events"`
+	}
+
+	OsvAffected struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges           []OsvRange `json:"ranges"`
+		Versions         []string   `json:"versions"`
+		DatabaseSpecific struct {
+			Severity string `json:"severity"`
+		} `json:"database_specific"`
+	}
+
+	Advisory struct {
+		ID        string   `json:"id"`
+		Aliases   []string `json:"aliases"`
+		Summary   string   `json:"summary"`
+		Withdrawn string   `json:"withdrawn"`
+		Severity  []struct {
+			Type  string `json:"type"`
+			Score string `json:"score"`
+		} `json:"severity"`
+		Affected         []OsvAffected `json:"affected"`
+		DatabaseSpecific struct {
+			Severity string `json:"severity"`
+		} `json:"database_specific"`
+	}
+
+	AdvisoryDB struct {
+		Advisories []*Advisory
+	}
+
+	AuditFinding struct {
+		Name     string
+		Revision string
+		Version  string
+		ID       string
+		Summary  string
+		Severity string
+		Fixed    []string
+	}
+)
+
+// known severities from lowest, advisory without one ranks above them all
+var severityLevels = []string{"low", "moderate", "high", "critical"}
+
+/**
+ * Rank of severity name, medium is the same as moderate. Unknown severity
+ * ranks highest so it is never let through
+ *
+ * @param {string} severity
+ * @return {int}
+ */
+func SeverityLevel(severity string) int {
+	severity = strings.ToLower(severity)
+	if severity == "medium" {
+		severity = "moderate"
+	}
+	for i, level := range severityLevels {
+		if level == severity {
+			return i
+		}
+	}
+	return len(severityLevels)
+}
+
+/**
+ * Parse severity threshold, one of low, moderate, medium, high or critical
+ *
+ * @param {string} severity
+ * @return {int}
+ * @return {error}
+ */
+func ParseSeverity(severity string) (int, error) {
+	if level := SeverityLevel(severity); level < len(severityLevels) {
+		return level, nil
+	}
+	return 0, fmt.Errorf("Unknown severity %s, expected one of %s", severity, strings.Join(severityLevels, ", "))
+}
+
+/**
+ * Load advisories in OSV json format from file, or from every json file
+ * inside dir. A file holds one advisory or an array of them
+ *
+ * @param {string} path
+ * @return {*AdvisoryDB}
+ * @return {error}
+ */
+func LoadAdvisories(path string) (*AdvisoryDB, error) {
+	db := &AdvisoryDB{Advisories: []*Advisory{}}
+
+	err := filepath.Walk(path, func(file string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || (file != path && filepath.Ext(file) != ".json") {
+			return err
+		}
+
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		advisories := []*Advisory{}
+		if content = bytes.TrimSpace(content); bytes.HasPrefix(content, []byte("[")) {
+			err = json.Unmarshal(content, &advisories)
+		} else {
+			advisory := &Advisory{}
+			err = json.Unmarshal(content, advisory)
+			advisories = append(advisories, advisory)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", file, err.Error())
+		}
+
+		for _, advisory := range advisories {
+			if advisory.ID != "" && advisory.Withdrawn == "" {
+				db.Advisories = append(db.Advisories, advisory)
+			}
+		}
+		return nil
+	})
+	return db, err
+}
+
+/**
+ * Match every package recorded in lock against advisories. Version of
+ * package is its module version or semver tag of its revision, revision
+ * itself is matched against git ranges. Returns findings and packages
+ * whose version is not known, so only git ranges could be checked
+ *
+ * @param {string} vendorDir
+ * @param {*Lock} lock
+ * @return {[]*AuditFinding}
+ * @return {[]string}
+ * @return {error}
+ */
+func (db *AdvisoryDB) Audit(vendorDir string, lock *Lock) ([]*AuditFinding, []string, error) {
+	findings := []*AuditFinding{}
+	unversioned := []string{}
+
+	for _, pkg := range lock.Packages {
+		dir := filepath.Join(vendorDir, filepath.FromSlash(pkg.Name))
+
+		version := ""
+		var isAncestor func(ref string) bool
+		if pkg.Vcs == PROXYVCS {
+			version = pkg.Revision
+		} else if vcs := VcsByName(pkg.Vcs); vcs != nil {
+			if tag := vcs.Tag(dir); tag != "" {
+				if _, err := ParseVersion(tag); err == nil {
+					version = tag
+				}
+			}
+			isAncestor = func(ref string) bool {
+				return vcs.IsAncestor(dir, ref)
+			}
+		} else {
+			return nil, nil, fmt.Errorf("%s: unknown vcs %s", pkg.Name, pkg.Vcs)
+		}
+		if version == "" {
+			unversioned = append(unversioned, pkg.Name)
+		}
+
+		for _, advisory := range db.Advisories {
+			for _, affected := range advisory.Affected {
+				if !affected.names(pkg.Name) || !affected.affects(version, isAncestor) {
+					continue
+				}
+				findings = append(findings, &AuditFinding{
+					Name:     pkg.Name,
+					Revision: pkg.Revision,
+					Version:  version,
+					ID:       advisory.ID,
+					Summary:  advisory.Summary,
+					Severity: advisory.severity(),
+					Fixed:    affected.fixed(),
+				})
+				break
+			}
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Name != findings[j].Name {
+			return findings[i].Name < findings[j].Name
+		}
+		return findings[i].ID < findings[j].ID
+	})
+	return findings, unversioned, nil
+}
+
+// severity name of advisory, from database specific field or from numeric
+// cvss score, unknown when neither is given
+func (a *Advisory) severity() string {
+	if a.DatabaseSpecific.Severity != "" {
+		return strings.ToLower(a.DatabaseSpecific.Severity)
+	}
+	for _, affected := range a.Affected {
+		if affected.DatabaseSpecific.Severity != "" {
+			return strings.ToLower(affected.DatabaseSpecific.Severity)
+		}
+	}
+
+	for _, severity := range a.Severity {
+		score, err := strconv.ParseFloat(severity.Score, 64)
+		switch {
+		case err != nil:
+		case score >= 9:
+			return "critical"
+		case score >= 7:
+			return "high"
+		case score >= 4:
+			return "moderate"
+		case score > 0:
+			return "low"
+		}
+	}
+	return "unknown"
+}
+
+// affected module is the package repository or lives inside it
+func (a OsvAffected) names(name string) bool {
+	ecosystem := a.Package.Ecosystem
+	if ecosystem != "" && ecosystem != "Go" && !strings.HasPrefix(ecosystem, "Go:") {
+		return false
+	}
+	return a.Package.Name == name || strings.HasPrefix(a.Package.Name, name+"/")
+}
+
+func (a OsvAffected) affects(version string, isAncestor func(ref string) bool) bool {
+	if version != "" {
+		for _, v := range a.Versions {
+			if strings.TrimPrefix(v, "v") == strings.TrimPrefix(version, "v") {
+				return true
+			}
+		}
+	}
+
+	for _, r := range a.Ranges {
+		switch r.Type {
+		case "SEMVER", "ECOSYSTEM":
+			if version != "" && r.affectsVersion(version) {
+				return true
+			}
+		case "GIT":
+			if isAncestor != nil && r.affectsRevision(isAncestor) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a OsvAffected) fixed() []string {
+	fixed := []string{}
+	for _, r := range a.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+		for _, event := range r.Events {
+			if event.Fixed != "" {
+				fixed = append(fixed, event.Fixed)
+			}
+		}
+	}
+	return fixed
+}
+
+// walk events from lowest version up to version, each introduced event
+// opens an affected range that fixed event closes
+func (r OsvRange) affectsVersion(version string) bool {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false
+	}
+
+	type event struct {
+		version *Version
+		kind    string
+	}
+	events := []event{}
+	for _, e := range r.Events {
+		for kind, s := range map[string]string{"introduced": e.Introduced, "fixed": e.Fixed, "last_affected": e.LastAffected} {
+			if s == "0" {
+				events = append(events, event{&Version{}, kind})
+			} else if ev, err := ParseVersion(s); err == nil {
+				events = append(events, event{ev, kind})
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].version.Compare(events[j].version) < 0
+	})
+
+	affected := false
+	for _, e := range events {
+		cmp := e.version.Compare(v)
+		if cmp > 0 {
+			break
+		}
+		switch e.kind {
+		case "introduced":
+			affected = true
+		case "fixed":
+			affected = false
+		case "last_affected":
+			affected = affected && cmp == 0
+		}
+	}
+	return affected
+}
+
+// revision is affected when some introducing commit is its ancestor and no
+// fixing commit is
+func (r OsvRange) affectsRevision(isAncestor func(ref string) bool) bool {
+	introduced := false
+	for _, e := range r.Events {
+		if e.Fixed != "" && isAncestor(e.Fixed) {
+			return false
+		}
+		if e.Introduced == "0" || (e.Introduced != "" && isAncestor(e.Introduced)) {
+			introduced = true
+		}
+	}
+	return introduced
+}
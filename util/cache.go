@@ -0,0 +1,294 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+/**
+ * Cache type, per user directory of bare repository mirrors keyed by
+ * repository import path, shared by every project. Mirror is only reused
+ * for the remote and vcs it mirrors. Offline cache never touches network
+ */
+type Cache struct {
+	Dir     string
+	Offline bool
+	mutex   sync.Mutex
+}
+
+/**
+ * Default cache dir, GOPAS_CACHE or gopas inside user cache dir
+ *
+ * @return {string}
+ */
+func DefaultCacheDir() string {
+	if dir := os.Getenv("GOPAS_CACHE"); dir != "" {
+		return dir
+	}
+
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "gopas")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".cache", "gopas")
+}
+
+func NewCache(dir string, offline bool) *Cache {
+	if dir == "" {
+		dir = DefaultCacheDir()
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	return &Cache{
+		Dir:     dir,
+		Offline: offline,
+	}
+}
+
+func (c *Cache) mirrorDir(repo string) string {
+	return filepath.Join(c.Dir, "git", filepath.FromSlash(repo))
+}
+
+/**
+ * Check whether repository is cached
+ *
+ * @param {string} repo
+ * @return {bool}
+ */
+func (c *Cache) Has(repo string) bool {
+	return isDir(c.mirrorDir(repo))
+}
+
+/**
+ * Check whether repository is cached as mirror of remote kept by vcs, only
+ * git repositories are mirrored
+ *
+ * @param {string} repo
+ * @param {*Vcs} vcs
+ * @param {string} remote
+ * @return {bool}
+ */
+func (c *Cache) Mirrors(repo string, vcs *Vcs, remote string) bool {
+	if vcs != VcsGit || !c.Has(repo) {
+		return false
+	}
+
+	mirrored, err := VcsGit.Remote(c.mirrorDir(repo))
+	return err == nil && mirrored == remote
+}
+
+/**
+ * Find cached repository containing package import path
+ *
+ * @param {string} importPath
+ * @return {string}
+ * @return {bool}
+ */
+func (c *Cache) Lookup(importPath string) (string, bool) {
+	for repo := importPath; repo != "." && repo != "/" && repo != ""; repo = filepath.ToSlash(filepath.Dir(repo)) {
+		if c.Has(repo) {
+			return repo, true
+		}
+	}
+	return "", false
+}
+
+/**
+ * Mirror repository checked out at dir into cache, pointing mirror to the
+ * same remote. Vcs without mirror support is not cached
+ *
+ * @param {string} repo
+ * @param {string} dir
+ * @return {error}
+ */
+func (c *Cache) Store(repo string, dir string) error {
+	var (
+		remote string
+		err    error
+	)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	vcs := VcsForDir(dir)
+	if vcs == nil || vcs.MirrorCmd == nil || c.Has(repo) {
+		return nil
+	}
+
+	if remote, err = vcs.Remote(dir); err != nil {
+		return err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return err
+	}
+
+	mirror := c.mirrorDir(repo)
+	if err = os.MkdirAll(filepath.Dir(mirror), 0755); err != nil {
+		return err
+	}
+	if _, err = vcs.run(filepath.Dir(mirror), vcs.MirrorCmd, "repo", dir, "dir", mirror); err != nil {
+		return err
+	}
+
+	_, err = vcs.run(mirror, vcs.SetRemoteCmd, "repo", remote)
+	return err
+}
+
+/**
+ * Mirror every repository inside vendor dir that is not cached yet. Not
+ * for use while dependencies are fetched, it would mirror repositories
+ * still being cloned, fetch stores each repository it clones instead
+ *
+ * @param {string} vendorDir
+ * @return {error}
+ */
+func (c *Cache) StoreAll(vendorDir string) error {
+	err := filepath.Walk(vendorDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() || path == vendorDir || VcsForDir(path) == nil {
+			return err
+		}
+
+		repo, err := filepath.Rel(vendorDir, path)
+		if err != nil {
+			return err
+		}
+		if err = c.Store(filepath.ToSlash(repo), path); err != nil {
+			return fmt.Errorf("%s: %s", repo, err.Error())
+		}
+		return filepath.SkipDir
+	})
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+/**
+ * Download new revisions of cached repository from its remote, nothing to
+ * do when offline
+ *
+ * @param {string} repo
+ * @return {error}
+ */
+func (c *Cache) Fetch(repo string) error {
+	if c.Offline {
+		return nil
+	}
+	return VcsGit.Download(c.mirrorDir(repo))
+}
+
+/**
+ * Create checkout at dest from cached repository, local clone shares
+ * objects through hardlinks
+ *
+ * @param {string} repo
+ * @param {string} dest
+ * @return {error}
+ */
+func (c *Cache) Clone(repo string, dest string) error {
+	mirror := c.mirrorDir(repo)
+
+	remote, err := VcsGit.Remote(mirror)
+	if err != nil {
+		return err
+	}
+
+	if err = VcsGit.Create(mirror, dest); err != nil {
+		return err
+	}
+
+	_, err = VcsGit.run(dest, VcsGit.SetRemoteCmd, "repo", remote)
+	return err
+}
+
+/**
+ * Bring revisions of cached repository into existing checkout at dest
+ *
+ * @param {string} repo
+ * @param {string} dest
+ * @return {error}
+ */
+func (c *Cache) Sync(repo string, dest string) error {
+	mirror, err := filepath.Abs(c.mirrorDir(repo))
+	if err != nil {
+		return err
+	}
+
+	_, err = VcsGit.run(dest, VcsGit.SyncCmd, "repo", mirror)
+	return err
+}
+
+/**
+ * Create checkout at dest from cache when cached from the same remote, from
+ * remote otherwise caching it for next time
+ *
+ * @param {string} repo
+ * @param {*Vcs} vcs
+ * @param {string} remote
+ * @param {string} dest
+ * @return {error}
+ */
+func (c *Cache) Create(repo string, vcs *Vcs, remote string, dest string) error {
+	if c.Mirrors(repo, vcs, remote) {
+		return c.Clone(repo, dest)
+	}
+
+	if c.Offline {
+		return c.missError(repo)
+	}
+
+	if err := vcs.Create(remote, dest); err != nil {
+		return err
+	}
+
+	// cache is best effort, failing to mirror must not fail the checkout
+	c.Store(repo, dest)
+	return nil
+}
+
+/**
+ * Checkout ref of repository repo at dir, offline cache only checks out
+ * revisions downloaded already. Nil cache is online
+ *
+ * @param {*Vcs} vcs
+ * @param {string} repo
+ * @param {string} dir
+ * @param {string} ref
+ * @return {error}
+ */
+func (c *Cache) Checkout(vcs *Vcs, repo string, dir string, ref string) error {
+	if c == nil || !c.Offline {
+		return vcs.Checkout(dir, ref)
+	}
+	if err := vcs.CheckoutLocal(dir, ref); err != nil {
+		return c.missError(repo + "@" + ref)
+	}
+	return nil
+}
+
+/**
+ * Checkout newest downloaded revision of branch ref like
+ * Vcs.CheckoutUpstream, offline cache never downloads missing ref
+ *
+ * @param {*Vcs} vcs
+ * @param {string} repo
+ * @param {string} dir
+ * @param {string} ref
+ * @return {error}
+ */
+func (c *Cache) CheckoutUpstream(vcs *Vcs, repo string, dir string, ref string) error {
+	if c == nil || !c.Offline {
+		return vcs.CheckoutUpstream(dir, ref)
+	}
+	return vcs.checkoutUpstream(dir, ref, func(dir string, ref string) error {
+		return c.Checkout(vcs, repo, dir, ref)
+	})
+}
+
+func (c *Cache) missError(repo string) error {
+	return fmt.Errorf("%s is not cached in %s and can not be downloaded offline", repo, c.Dir)
+}
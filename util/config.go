@@ -0,0 +1,203 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	USERCONFIG = ".gopas.yml"
+)
+
+/**
+ * Config type, user level configuration shared by every project
+ */
+type Config struct {
+	Rewrites   []Rewrite
+	Proxy      string
+	Advisories string
+}
+
+/**
+ * Rewrite type, rule routing fetch of repositories matching pattern to
+ * another remote. Each * of pattern matches one path element and is
+ * substituted into remote in order
+ */
+type Rewrite struct {
+	Pattern string
+	Vcs     string
+	Remote  string
+}
+
+/**
+ * User config file, GOPAS_CONFIG or .gopas.yml inside home dir
+ *
+ * @return {string}
+ */
+func UserConfigFile() string {
+	if file := os.Getenv("GOPAS_CONFIG"); file != "" {
+		return file
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	return filepath.Join(home, USERCONFIG)
+}
+
+/**
+ * Read user config, empty config when file does not exist
+ *
+ * @param {string} file
+ * @return {*Config}
+ * @return {error}
+ */
+func ReadConfig(file string) (*Config, error) {
+	config := &Config{Rewrites: []Rewrite{}}
+
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	raw := struct {
+		Rewrites   []string
+		Proxy      string
+		Advisories string
+	}{}
+	if err = yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	config.Proxy = raw.Proxy
+
+	// advisory database may be given relative to config file
+	config.Advisories = raw.Advisories
+	if config.Advisories != "" && !filepath.IsAbs(config.Advisories) {
+		config.Advisories = filepath.Join(filepath.Dir(file), config.Advisories)
+	}
+
+	for _, rule := range raw.Rewrites {
+		rewrite, err := ParseRewrite(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err.Error())
+		}
+		config.Rewrites = append(config.Rewrites, rewrite)
+	}
+	return config, nil
+}
+
+/**
+ * Parse rewrite rule written as pattern => remote, remote may be prefixed
+ * by hg+, bzr+ or svn+ when it is not a git repository
+ *
+ * @param {string} s
+ * @return {Rewrite}
+ * @return {error}
+ */
+func ParseRewrite(s string) (Rewrite, error) {
+	match := localDependencyRe.FindStringSubmatch(s)
+	if match == nil || match[2] == "" {
+		return Rewrite{}, fmt.Errorf("Invalid rewrite rule %q", s)
+	}
+
+	rewrite := Rewrite{
+		Pattern: strings.TrimSuffix(match[1], "/"),
+		Vcs:     VcsGit.Name,
+		Remote:  match[2],
+	}
+
+	if i := strings.Index(rewrite.Remote, "+"); i > 0 && VcsByName(rewrite.Remote[:i]) != nil {
+		rewrite.Vcs, rewrite.Remote = rewrite.Remote[:i], rewrite.Remote[i+1:]
+	}
+
+	if strings.Count(rewrite.Remote, "*") > strings.Count(rewrite.Pattern, "*") {
+		return Rewrite{}, fmt.Errorf("Rewrite rule %q uses more * than it matches", s)
+	}
+	return rewrite, nil
+}
+
+/**
+ * Match import path against rule, returning repository root it matched
+ * and remote to fetch that repository from
+ *
+ * @param {string} importPath
+ * @return {string}
+ * @return {string}
+ * @return {bool}
+ */
+func (r Rewrite) Match(importPath string) (string, string, bool) {
+	patterns := strings.Split(r.Pattern, "/")
+	elements := strings.Split(importPath, "/")
+	if len(elements) < len(patterns) {
+		return "", "", false
+	}
+
+	remote := r.Remote
+	for i, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, elements[i]); !matched {
+			return "", "", false
+		}
+		if strings.Contains(pattern, "*") {
+			remote = strings.Replace(remote, "*", elements[i], 1)
+		}
+	}
+	return strings.Join(elements[:len(patterns)], "/"), remote, true
+}
+
+/**
+ * Advisory database path, GOPAS_ADVISORIES overrides config
+ *
+ * @return {string}
+ */
+func (c *Config) AdvisoriesPath() string {
+	if env := os.Getenv("GOPAS_ADVISORIES"); env != "" {
+		return env
+	}
+	return c.Advisories
+}
+
+/**
+ * Module proxy url, GOPAS_PROXY overrides config. Empty when packages are
+ * cloned from their repositories
+ *
+ * @return {string}
+ */
+func (c *Config) ProxyURL() string {
+	url := c.Proxy
+	if env, ok := os.LookupEnv("GOPAS_PROXY"); ok {
+		url = env
+	}
+
+	// accept GOPROXY style list, only the first proxy is used
+	url = strings.TrimSpace(strings.SplitN(url, ",", 2)[0])
+	if url == "direct" || url == "off" {
+		return ""
+	}
+	return url
+}
+
+/**
+ * Find first rewrite rule matching import path
+ *
+ * @param {string} importPath
+ * @return {string}
+ * @return {*Vcs}
+ * @return {string}
+ * @return {bool}
+ */
+func (c *Config) Rewrite(importPath string) (string, *Vcs, string, bool) {
+	for _, rewrite := range c.Rewrites {
+		if repo, remote, ok := rewrite.Match(importPath); ok {
+			return repo, VcsByName(rewrite.Vcs), remote, true
+		}
+	}
+	return "", nil, "", false
+}
@@ -0,0 +1,102 @@
+package util
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/**
+ * DepsReport type compares declared dependencies with real imports
+ */
+type DepsReport struct {
+	Unused     []Dependency
+	Undeclared []string
+	Missing    []string
+}
+
+/**
+ * Check declared dependencies against import graph, declared repository no
+ * project package reaches is unused, repository imported directly by
+ * project without being declared is undeclared. Graph should count imports
+ * of project tests, or dependencies of tests are reported unused
+ *
+ * @param {*ImportGraph} graph
+ * @param {[]Dependency} dependencies
+ * @param {string} vendorDir
+ * @return {*DepsReport}
+ */
+func CheckDependencies(graph *ImportGraph, dependencies []Dependency, vendorDir string) *DepsReport {
+	report := &DepsReport{
+		Unused:     []Dependency{},
+		Undeclared: []string{},
+		Missing:    []string{},
+	}
+
+	// package belongs to dependency when it is inside dependency package or
+	// lives in the same vendored repository
+	belongs := func(pkg string, dep Dependency) bool {
+		return pkg == dep.Name || strings.HasPrefix(pkg, dep.Name+"/") ||
+			(dep.Path == "" && repoPath(vendorDir, pkg) == repoPath(vendorDir, dep.Name))
+	}
+	declares := func(pkg string) bool {
+		for _, dep := range dependencies {
+			if belongs(pkg, dep) {
+				return true
+			}
+		}
+		return false
+	}
+
+	reachable := graph.Reachable()
+	for _, dep := range dependencies {
+		used := false
+		for _, pkg := range reachable {
+			if used = belongs(pkg, dep); used {
+				break
+			}
+		}
+		if !used {
+			report.Unused = append(report.Unused, dep)
+		}
+	}
+
+	undeclared := map[string]bool{}
+	for _, pkg := range graph.Direct() {
+		switch repo := repoPath(vendorDir, pkg); {
+		case declares(pkg):
+		case graph.Dirs[pkg] == "":
+			report.Missing = append(report.Missing, pkg)
+		case !undeclared[repo]:
+			undeclared[repo] = true
+			report.Undeclared = append(report.Undeclared, repo)
+		}
+	}
+	sort.Strings(report.Undeclared)
+
+	return report
+}
+
+/**
+ * Check whether report found nothing wrong
+ *
+ * @return {bool}
+ */
+func (r *DepsReport) IsClean() bool {
+	return len(r.Unused) == 0 && len(r.Undeclared) == 0 && len(r.Missing) == 0
+}
+
+// import path of repository holding package, package itself when it is not
+// inside a repository under vendor dir
+func repoPath(vendorDir string, pkg string) string {
+	root, _, err := FindRepoRoot(vendorDir, filepath.Join(vendorDir, filepath.FromSlash(pkg)))
+	if err != nil {
+		return pkg
+	}
+
+	rel, err := filepath.Rel(vendorDir, root)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return pkg
+	}
+	return filepath.ToSlash(rel)
+}
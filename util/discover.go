@@ -0,0 +1,95 @@
+package util
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// client used to discover repository of import path, tests swap it
+var DiscoverClient = &http.Client{Timeout: time.Minute}
+
+/**
+ * Discover repository root, vcs and remote of import path from go-import
+ * meta tag served at https://<import path>?go-get=1, the way go get does
+ *
+ * @param {string} importPath
+ * @return {string}
+ * @return {*Vcs}
+ * @return {string}
+ * @return {error}
+ */
+func DiscoverRepo(importPath string) (string, *Vcs, string, error) {
+	resp, err := DiscoverClient.Get("https://" + importPath + "?go-get=1")
+	if err != nil {
+		return "", nil, "", err
+	}
+	defer resp.Body.Close()
+
+	metas, err := parseGoImport(resp.Body)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("%s: %s", importPath, err.Error())
+	}
+
+	for _, meta := range metas {
+		if importPath != meta[0] && !strings.HasPrefix(importPath, meta[0]+"/") {
+			continue
+		}
+		vcs := VcsByName(meta[1])
+		if vcs == nil {
+			return "", nil, "", fmt.Errorf("%s: unsupported vcs %s", importPath, meta[1])
+		}
+		return meta[0], vcs, meta[2], nil
+	}
+	return "", nil, "", fmt.Errorf("%s: no go-import meta tag", importPath)
+}
+
+// read prefix, vcs and remote of go-import meta tags inside html head
+func parseGoImport(r io.Reader) ([][3]string, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	metas := [][3]string{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return metas, nil
+		} else if err != nil {
+			return metas, err
+		}
+
+		if end, ok := token.(xml.EndElement); ok && strings.EqualFold(end.Name.Local, "head") {
+			return metas, nil
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if strings.EqualFold(start.Name.Local, "body") {
+			return metas, nil
+		}
+		if !strings.EqualFold(start.Name.Local, "meta") || xmlAttr(start, "name") != "go-import" {
+			continue
+		}
+		if fields := strings.Fields(xmlAttr(start, "content")); len(fields) == 3 {
+			metas = append(metas, [3]string{fields[0], fields[1], fields[2]})
+		}
+	}
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if strings.EqualFold(attr.Name.Local, name) {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -1,5 +0,0 @@
-package util
-
-import "errors"
-
-var PackageFound = errors.New("!!found")
@@ -0,0 +1,393 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	GOMODFILE = "go.mod"
+	GOSUMFILE = "go.sum"
+
+	// version go writes for module required only to be replaced by a dir
+	zeroPseudoVersion = "v0.0.0-00010101000000-000000000000"
+)
+
+var (
+	pseudoVersionRe = regexp.MustCompile(`[-.]\d{14}-([0-9a-f]{12})$`)
+	pathMajorRe     = regexp.MustCompile(`^(?:gopkg\.in/.*\.v(\d+)|.*/v(\d+))$`)
+)
+
+/**
+ * GoMod type, what gopas reads from and writes to go.mod
+ */
+type (
+	GoModRequire struct {
+		Path     string
+		Version  string
+		Indirect bool
+	}
+
+	GoModReplace struct {
+		Path string
+		Dir  string
+	}
+
+	GoMod struct {
+		Module   string
+		Go       string
+		Requires []GoModRequire
+		Replaces []GoModReplace
+	}
+)
+
+/**
+ * Read go.mod from dir, nil when file does not exist. Only replacements by
+ * local dir are read, those by another module are left out
+ *
+ * @param {string} dir
+ * @return {*GoMod}
+ * @return {error}
+ */
+func ReadGoMod(dir string) (*GoMod, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, GOMODFILE))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	mod := &GoMod{}
+	block := ""
+	for i, line := range strings.Split(string(content), "\n") {
+		indirect := strings.Contains(line, "// indirect")
+		if j := strings.Index(line, "//"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		for j, field := range fields {
+			if unquoted, err := strconv.Unquote(field); err == nil {
+				fields[j] = unquoted
+			}
+		}
+
+		verb := block
+		switch {
+		case len(fields) == 0:
+			continue
+		case block != "" && fields[0] == ")":
+			block = ""
+			continue
+		case block == "" && len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		case block == "":
+			verb, fields = fields[0], fields[1:]
+		}
+
+		switch verb {
+		case "module":
+			if len(fields) != 1 {
+				return nil, fmt.Errorf("%s:%d: malformed module", GOMODFILE, i+1)
+			}
+			mod.Module = fields[0]
+		case "go":
+			if len(fields) != 1 {
+				return nil, fmt.Errorf("%s:%d: malformed go", GOMODFILE, i+1)
+			}
+			mod.Go = fields[0]
+		case "require":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s:%d: malformed require", GOMODFILE, i+1)
+			}
+			mod.Requires = append(mod.Requires, GoModRequire{fields[0], fields[1], indirect})
+		case "replace":
+			arrow := 0
+			for arrow < len(fields) && fields[arrow] != "=>" {
+				arrow++
+			}
+			if arrow == 0 || arrow > 2 || arrow+1 >= len(fields) {
+				return nil, fmt.Errorf("%s:%d: malformed replace", GOMODFILE, i+1)
+			}
+			if target := fields[arrow+1]; isLocalModuleDir(target) {
+				mod.Replaces = append(mod.Replaces, GoModReplace{fields[0], target})
+			}
+		}
+	}
+	return mod, nil
+}
+
+// go tells dir from module path by leading ./, ../ or /
+func isLocalModuleDir(path string) bool {
+	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || filepath.IsAbs(path)
+}
+
+/**
+ * Describe locked packages as module requirements of project name. Module
+ * path is read from go.mod of vendored package when it has one, version is
+ * its semver tag or a pseudo-version of its revision. Packages not declared
+ * as dependencies are marked indirect, local dependencies are replaced by
+ * their dir
+ *
+ * @param {string} vendorDir
+ * @param {string} name
+ * @param {[]Dependency} dependencies
+ * @param {*Lock} lock
+ * @return {*GoMod}
+ * @return {error}
+ */
+func NewGoMod(vendorDir string, name string, dependencies []Dependency, lock *Lock) (*GoMod, error) {
+	mod := &GoMod{Module: name}
+
+	for _, pkg := range lock.Packages {
+		path, version, err := moduleVersion(vendorDir, pkg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", pkg.Name, err.Error())
+		}
+
+		direct := false
+		for _, dep := range dependencies {
+			if dep.Path == "" && (dep.Name == pkg.Name || strings.HasPrefix(dep.Name, pkg.Name+"/")) {
+				direct = true
+			}
+		}
+		mod.Requires = append(mod.Requires, GoModRequire{path, version, !direct})
+	}
+
+	for _, dep := range dependencies {
+		if dep.Path == "" {
+			continue
+		}
+		dir := filepath.ToSlash(dep.Path)
+		if !isLocalModuleDir(dir) {
+			dir = "./" + dir
+		}
+		mod.Requires = append(mod.Requires, GoModRequire{dep.Name, zeroPseudoVersion, false})
+		mod.Replaces = append(mod.Replaces, GoModReplace{dep.Name, dir})
+	}
+
+	sort.Slice(mod.Requires, func(i, j int) bool {
+		return mod.Requires[i].Path < mod.Requires[j].Path
+	})
+	return mod, nil
+}
+
+// module path and version of locked package, the vendored checkout must be
+// at the locked revision
+func moduleVersion(vendorDir string, pkg LockedPackage) (string, string, error) {
+	dir := filepath.Join(vendorDir, filepath.FromSlash(pkg.Name))
+
+	path := pkg.Name
+	own, err := ReadGoMod(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if own != nil && own.Module != "" {
+		path = own.Module
+	}
+
+	if pkg.Vcs == PROXYVCS {
+		return path, pkg.Revision, nil
+	}
+
+	vcs := VcsByName(pkg.Vcs)
+	if vcs == nil {
+		return "", "", fmt.Errorf("unknown vcs %s", pkg.Vcs)
+	}
+	if revision, err := vcs.Revision(dir); err != nil {
+		return "", "", err
+	} else if revision != pkg.Revision {
+		return "", "", fmt.Errorf("vendored revision %s is not locked revision %s", ShortRevision(revision), ShortRevision(pkg.Revision))
+	}
+
+	major := 0
+	if match := pathMajorRe.FindStringSubmatch(path); match != nil {
+		major, _ = strconv.Atoi(match[1] + match[2])
+	}
+
+	// go only knows tags like v1.2.3, major 2 and above belongs in module
+	// path unless package predates modules
+	if tag := vcs.Tag(dir); strings.HasPrefix(tag, "v") && !strings.Contains(tag, "+") {
+		if v, err := ParseVersion(tag); err == nil {
+			switch {
+			case v.Major == major || (major == 0 && v.Major <= 1):
+				return path, tag, nil
+			case major == 0 && own == nil:
+				return path, tag + "+incompatible", nil
+			}
+		}
+	}
+
+	t, err := vcs.Time(dir)
+	if err != nil {
+		return "", "", err
+	}
+	base := "v0.0.0"
+	if major >= 2 {
+		base = fmt.Sprintf("v%d.0.0", major)
+	}
+	return path, fmt.Sprintf("%s-%s-%s", base, t.Format("20060102150405"), ShortRevision(pkg.Revision)), nil
+}
+
+/**
+ * Write go.mod into dir
+ *
+ * @param {string} dir
+ * @return {error}
+ */
+func (m *GoMod) Write(dir string) error {
+	lines := []string{"module " + m.Module}
+	if m.Go != "" {
+		lines = append(lines, "", "go "+m.Go)
+	}
+
+	requires := []string{}
+	for _, req := range m.Requires {
+		line := req.Path + " " + req.Version
+		if req.Indirect {
+			line += " // indirect"
+		}
+		requires = append(requires, line)
+	}
+	lines = append(lines, goModDirective("require", requires)...)
+
+	replaces := []string{}
+	for _, rep := range m.Replaces {
+		replaces = append(replaces, rep.Path+" => "+rep.Dir)
+	}
+	lines = append(lines, goModDirective("replace", replaces)...)
+
+	return ioutil.WriteFile(filepath.Join(dir, GOMODFILE), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+/**
+ * Version for go directive of go.mod from toolchain version as go version
+ * prints it, e.g. go1.21.3 gives 1.21.3. Toolchains before go1.21 only
+ * accept major.minor, development builds give empty version
+ *
+ * @param {string} version
+ * @return {string}
+ */
+func GoDirectiveVersion(version string) string {
+	if !strings.HasPrefix(version, "go1.") {
+		return ""
+	}
+	version = strings.TrimPrefix(strings.Fields(version)[0], "go")
+
+	// minor may be followed by patch or prerelease, e.g. 1.22rc1
+	minor := strings.SplitN(version, ".", 3)[1]
+	if i := strings.IndexFunc(minor, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		minor = minor[:i]
+	}
+	if n, err := strconv.Atoi(minor); err != nil {
+		return ""
+	} else if n < 21 {
+		return "1." + minor
+	}
+	return version
+}
+
+// format directive on one line, or as block when there are more lines
+func goModDirective(verb string, lines []string) []string {
+	switch len(lines) {
+	case 0:
+		return nil
+	case 1:
+		return []string{"", verb + " " + lines[0]}
+	}
+
+	block := []string{"", verb + " ("}
+	for _, line := range lines {
+		block = append(block, "\t"+line)
+	}
+	return append(block, ")")
+}
+
+/**
+ * Convert into manifest. Indirect requirements are left to be resolved from
+ * dependencies, pseudo-versions become their revision and modules replaced
+ * by local dir become local dependencies
+ *
+ * @return {*Manifest}
+ */
+func (m *GoMod) Manifest() *Manifest {
+	replaced := map[string]string{}
+	for _, rep := range m.Replaces {
+		replaced[rep.Path] = strings.TrimPrefix(rep.Dir, "./")
+	}
+
+	manifest := &Manifest{Name: m.Module}
+	for _, req := range m.Requires {
+		if dir, ok := replaced[req.Path]; ok {
+			manifest.Dependencies = append(manifest.Dependencies, Dependency{Name: req.Path, Path: dir})
+			continue
+		}
+		if req.Indirect {
+			continue
+		}
+
+		version := strings.TrimSuffix(req.Version, "+incompatible")
+		if match := pseudoVersionRe.FindStringSubmatch(version); match != nil {
+			version = match[1]
+		}
+		manifest.Dependencies = append(manifest.Dependencies, Dependency{Name: req.Path, Version: version})
+	}
+	return manifest
+}
+
+/**
+ * Lines of go.sum for modules locked from proxy with hash recorded in
+ * gopas.sum. Hashes of vcs checkouts do not match module zips, so other
+ * packages are left for go to add
+ *
+ * @param {string} vendorDir
+ * @param {*Lock} lock
+ * @param {*Sum} sum
+ * @return {[]string}
+ * @return {error}
+ */
+func GoSumLines(vendorDir string, lock *Lock, sum *Sum) ([]string, error) {
+	lines := []string{}
+	if sum == nil {
+		return lines, nil
+	}
+
+	recorded := map[string]string{}
+	for _, entry := range sum.Entries {
+		recorded[entry.Name+"@"+entry.Revision] = entry.Hash
+	}
+
+	for _, pkg := range lock.Packages {
+		hash, ok := recorded[pkg.Name+"@"+pkg.Revision]
+		if pkg.Vcs != PROXYVCS || !ok {
+			continue
+		}
+
+		// proxy serves go.mod of module, or one naming only the module
+		// when it has none
+		content, err := ioutil.ReadFile(filepath.Join(vendorDir, filepath.FromSlash(pkg.Name), GOMODFILE))
+		if os.IsNotExist(err) {
+			content, err = []byte("module "+pkg.Name+"\n"), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		modHash, err := HashGoMod(content)
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines,
+			pkg.Name+" "+pkg.Revision+" "+hash,
+			pkg.Name+" "+pkg.Revision+"/go.mod "+modHash)
+	}
+
+	sort.Strings(lines)
+	return lines, nil
+}
@@ -0,0 +1,271 @@
+package util
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/**
+ * ImportGraph type, imports of every project package and every package it
+ * reaches through the gopath src dirs
+ */
+type ImportGraph struct {
+	Name    string
+	Imports map[string][]string
+	Dirs    map[string]string
+	srcDirs []string
+	context build.Context
+	tests   bool
+}
+
+/**
+ * Build import graph of project name, first src dir holds the project
+ * copy, the rest are searched in order to resolve imports
+ *
+ * @param {string} name
+ * @param {[]string} srcDirs
+ * @return {*ImportGraph}
+ * @return {error}
+ */
+func NewImportGraph(name string, srcDirs ...string) (*ImportGraph, error) {
+	return newImportGraph(build.Default, false, name, srcDirs)
+}
+
+/**
+ * Build import graph like NewImportGraph, but counting imports of files
+ * for every platform and build tag, and imports of project tests
+ *
+ * @param {string} name
+ * @param {[]string} srcDirs
+ * @return {*ImportGraph}
+ * @return {error}
+ */
+func NewFullImportGraph(name string, srcDirs ...string) (*ImportGraph, error) {
+	context := build.Default
+	context.UseAllFiles = true
+	return newImportGraph(context, true, name, srcDirs)
+}
+
+func newImportGraph(context build.Context, tests bool, name string, srcDirs []string) (*ImportGraph, error) {
+	g := &ImportGraph{
+		Name:    name,
+		Imports: map[string][]string{},
+		Dirs:    map[string]string{},
+		srcDirs: srcDirs,
+		context: context,
+		tests:   tests,
+	}
+
+	// walk where project really is, it may be linked into src dir
+	projectDir := filepath.Join(srcDirs[0], filepath.FromSlash(name))
+	realDir, err := filepath.EvalSymlinks(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.Walk(realDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() {
+			return err
+		}
+
+		if path != realDir && isIgnoredPackageDir(fi.Name()) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(realDir, path)
+		if err != nil {
+			return err
+		}
+		importPath := name
+		if rel != "." {
+			importPath += "/" + filepath.ToSlash(rel)
+		}
+		return g.add(importPath, filepath.Join(projectDir, rel))
+	})
+	return g, err
+}
+
+func isIgnoredPackageDir(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor"
+}
+
+// add package at dir and everything it imports, recursively
+func (g *ImportGraph) add(importPath string, dir string) error {
+	if _, ok := g.Imports[importPath]; ok {
+		return nil
+	}
+
+	pkg, err := g.context.ImportDir(dir, 0)
+	if _, ok := err.(*build.NoGoError); ok {
+		return nil
+	} else if err != nil {
+		if _, ok := err.(*build.MultiplePackageError); !ok && pkg == nil {
+			return err
+		}
+	}
+
+	g.Dirs[importPath] = dir
+	g.Imports[importPath] = []string{}
+
+	imports := pkg.Imports
+	if g.tests && g.IsProject(importPath) {
+		imports = append(append(imports, pkg.TestImports...), pkg.XTestImports...)
+	}
+	for _, imp := range imports {
+		if isStandardImport(imp) || imp == importPath {
+			continue
+		}
+		g.Imports[importPath] = append(g.Imports[importPath], imp)
+
+		if impDir := g.resolve(imp, dir); impDir != "" {
+			if err := g.add(imp, impDir); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// find dir of import path, vendor dirs up from importing dir win over src
+// dirs like go tool does
+func (g *ImportGraph) resolve(importPath string, fromDir string) string {
+	for _, srcDir := range g.srcDirs {
+		if !strings.HasPrefix(fromDir, srcDir+string(filepath.Separator)) {
+			continue
+		}
+		for dir := fromDir; dir != srcDir; dir = filepath.Dir(dir) {
+			if isDir(filepath.Join(dir, "vendor", filepath.FromSlash(importPath))) {
+				return filepath.Join(dir, "vendor", filepath.FromSlash(importPath))
+			}
+		}
+	}
+
+	for _, srcDir := range g.srcDirs {
+		if dir := filepath.Join(srcDir, filepath.FromSlash(importPath)); isDir(dir) {
+			return dir
+		}
+	}
+	return ""
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+func isStandardImport(importPath string) bool {
+	first := strings.SplitN(importPath, "/", 2)[0]
+	return !strings.Contains(first, ".") || importPath == "C"
+}
+
+/**
+ * Check whether package belongs to project itself
+ *
+ * @param {string} importPath
+ * @return {bool}
+ */
+func (g *ImportGraph) IsProject(importPath string) bool {
+	return importPath == g.Name || strings.HasPrefix(importPath, g.Name+"/")
+}
+
+/**
+ * Project packages, sorted
+ *
+ * @return {[]string}
+ */
+func (g *ImportGraph) Roots() []string {
+	roots := []string{}
+	for pkg := range g.Imports {
+		if g.IsProject(pkg) {
+			roots = append(roots, pkg)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+/**
+ * Packages outside project imported directly by project packages, sorted
+ *
+ * @return {[]string}
+ */
+func (g *ImportGraph) Direct() []string {
+	found := map[string]bool{}
+	for _, root := range g.Roots() {
+		for _, imp := range g.Imports[root] {
+			if !g.IsProject(imp) {
+				found[imp] = true
+			}
+		}
+	}
+	return sortedKeys(found)
+}
+
+/**
+ * Packages outside project reachable from project packages, sorted
+ *
+ * @return {[]string}
+ */
+func (g *ImportGraph) Reachable() []string {
+	found := map[string]bool{}
+	queue := g.Roots()
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		for _, imp := range g.Imports[pkg] {
+			if !found[imp] && !g.IsProject(imp) {
+				found[imp] = true
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return sortedKeys(found)
+}
+
+/**
+ * Shortest import chain from project to package, or to any package inside
+ * it when target is a repository path. Nil when not imported at all
+ *
+ * @param {string} target
+ * @return {[]string}
+ */
+func (g *ImportGraph) Why(target string) []string {
+	from := map[string]string{}
+	queue := g.Roots()
+	for _, root := range queue {
+		from[root] = ""
+	}
+
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		if pkg == target || strings.HasPrefix(pkg, target+"/") {
+			chain := []string{}
+			for ; pkg != ""; pkg = from[pkg] {
+				chain = append([]string{pkg}, chain...)
+			}
+			return chain
+		}
+
+		for _, imp := range g.Imports[pkg] {
+			if _, ok := from[imp]; !ok {
+				from[imp] = pkg
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
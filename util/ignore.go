@@ -0,0 +1,212 @@
+package util
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	GOPASIGNORE = ".gopasignore"
+)
+
+// top level dirs of project always ignored
+var ignoredRoots = map[string]bool{".gopath": true, "_vendor": true}
+
+/**
+ * Ignore type, gitignore like patterns of paths inside project dir that are
+ * neither copied into gopath nor watched
+ */
+type (
+	Ignore struct {
+		Dir   string
+		rules []ignoreRule
+	}
+
+	ignoreRule struct {
+		re      *regexp.Regexp
+		negate  bool
+		dirOnly bool
+	}
+)
+
+/**
+ * Read ignore of project at dir, patterns given first then lines of
+ * .gopasignore, so the file may negate them. Missing file is no error
+ *
+ * @param {string} dir
+ * @param {[]string} patterns
+ * @return {*Ignore}
+ * @return {error}
+ */
+func ReadIgnore(dir string, patterns []string) (*Ignore, error) {
+	ignore := NewIgnore(dir, patterns)
+
+	f, err := os.Open(filepath.Join(dir, GOPASIGNORE))
+	if os.IsNotExist(err) {
+		return ignore, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ignore.Add(scanner.Text())
+	}
+	return ignore, scanner.Err()
+}
+
+/**
+ * New ignore of dir with patterns
+ *
+ * @param {string} dir
+ * @param {[]string} patterns
+ * @return {*Ignore}
+ */
+func NewIgnore(dir string, patterns []string) *Ignore {
+	ignore := &Ignore{Dir: dir}
+	for _, pattern := range patterns {
+		ignore.Add(pattern)
+	}
+	return ignore
+}
+
+/**
+ * Add pattern written like a .gitignore line: # starts comment, ! negates,
+ * trailing / matches dirs only, pattern with other / is relative to dir
+ * while one without matches name at any depth, ** matches any number of
+ * dirs
+ *
+ * @param {string} pattern
+ */
+func (i *Ignore) Add(pattern string) {
+	pattern = strings.TrimRight(pattern, "\r")
+	if !strings.HasSuffix(pattern, "\\ ") {
+		pattern = strings.TrimRight(pattern, " \t")
+	}
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return
+	}
+
+	rule := ignoreRule{}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate, pattern = true, pattern[1:]
+	} else if strings.HasPrefix(pattern, "\\!") || strings.HasPrefix(pattern, "\\#") {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly, pattern = true, strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	expr := "^"
+	if !anchored {
+		expr += "(?:.*/)?"
+	}
+	expr += ignorePatternExpr(pattern) + "$"
+
+	if re, err := regexp.Compile(expr); err == nil {
+		rule.re = re
+		i.rules = append(i.rules, rule)
+	}
+}
+
+// translate glob of gitignore pattern into regexp
+func ignorePatternExpr(pattern string) string {
+	expr := ""
+	for j := 0; j < len(pattern); j++ {
+		c := pattern[j]
+		switch {
+		case strings.HasPrefix(pattern[j:], "**/") && (j == 0 || pattern[j-1] == '/'):
+			expr += "(?:.*/)?"
+			j += 2
+		case pattern[j:] == "**" && (j == 0 || pattern[j-1] == '/'):
+			expr += ".*"
+			j++
+		case c == '*':
+			expr += "[^/]*"
+		case c == '?':
+			expr += "[^/]"
+		case c == '[':
+			end := strings.IndexByte(pattern[j+1:], ']')
+			if end < 0 {
+				expr += regexp.QuoteMeta("[")
+				continue
+			}
+			class := pattern[j+1 : j+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr += "[" + class + "]"
+			j += end + 1
+		case c == '\\' && j+1 < len(pattern):
+			j++
+			expr += regexp.QuoteMeta(pattern[j : j+1])
+		default:
+			expr += regexp.QuoteMeta(string(c))
+		}
+	}
+	return expr
+}
+
+/**
+ * Tell whether path relative to dir is ignored, last matching pattern
+ * wins. Vcs dirs, .gopath and _vendor are always ignored. Caller walking
+ * dir does not descend into ignored dirs, so their files stay ignored
+ *
+ * @param {string} rel
+ * @param {bool} isDir
+ * @return {bool}
+ */
+func (i *Ignore) Match(rel string, isDir bool) bool {
+	rel = filepath.ToSlash(rel)
+	if ignoredRoots[rel] || isVcsMeta(filepath.Base(rel)) {
+		return true
+	}
+	if i == nil {
+		return false
+	}
+
+	ignored := false
+	for _, rule := range i.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+/**
+ * Tell whether path is ignored, path outside dir never is
+ *
+ * @param {string} path
+ * @param {bool} isDir
+ * @return {bool}
+ */
+func (i *Ignore) MatchPath(path string, isDir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	dir, err := filepath.Abs(i.Dir)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return i.Match(rel, isDir)
+}
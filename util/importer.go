@@ -0,0 +1,324 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type manifestImporter struct {
+	files  []string
+	read   func(dir string) (*Manifest, error)
+	source string
+}
+
+var manifestImporters = []manifestImporter{
+	{[]string{"Godeps/Godeps.json"}, importGodep, "godep"},
+	{[]string{"glide.lock", "glide.yaml"}, importGlide, "glide"},
+	{[]string{"Gopkg.lock", "Gopkg.toml"}, importDep, "dep"},
+	{[]string{"vendor/vendor.json"}, importGovendor, "govendor"},
+	{[]string{GOMODFILE}, importGoMod, "gomod"},
+}
+
+/**
+ * Read manifest of another dependency tool found at dir, pinned revisions
+ * become dependency versions, version constraints are carried over
+ * otherwise. Only manifest of source is read unless source is empty.
+ * Returns name of tool the manifest was read from
+ *
+ * @param {string} dir
+ * @param {string} source
+ * @return {*Manifest}
+ * @return {string}
+ * @return {error}
+ */
+func ImportManifest(dir string, source string) (*Manifest, string, error) {
+	sources := []string{}
+	for _, importer := range manifestImporters {
+		sources = append(sources, importer.source)
+		if source != "" && source != importer.source {
+			continue
+		}
+
+		for _, file := range importer.files {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+				continue
+			}
+
+			manifest, err := importer.read(dir)
+			if err != nil {
+				return nil, "", fmt.Errorf("%s: %s", importer.source, err.Error())
+			}
+			sort.Slice(manifest.Dependencies, func(i, j int) bool {
+				return manifest.Dependencies[i].Name < manifest.Dependencies[j].Name
+			})
+			return manifest, importer.source, nil
+		}
+
+		if source != "" {
+			return nil, "", fmt.Errorf("No %s manifest found", source)
+		}
+	}
+
+	if source != "" {
+		return nil, "", fmt.Errorf("Unknown source %s, expected one of %s", source, strings.Join(sources, ", "))
+	}
+	return nil, "", errors.New("No Godeps, glide, dep, govendor or go.mod manifest found")
+}
+
+func readImported(dir string, file string, v interface{}, unmarshal func([]byte, interface{}) error) (bool, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err = unmarshal(content, v); err != nil {
+		return false, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	return true, nil
+}
+
+// godep and govendor list every package, packages inside another listed
+// package at the same revision belong to the same repository
+func collapsePackages(dependencies []Dependency) []Dependency {
+	sort.Slice(dependencies, func(i, j int) bool {
+		return dependencies[i].Name < dependencies[j].Name
+	})
+
+	collapsed := []Dependency{}
+	for _, dep := range dependencies {
+		if n := len(collapsed); n > 0 && collapsed[n-1].Version == dep.Version && collapsed[n-1].Remote == dep.Remote &&
+			(collapsed[n-1].Name == dep.Name || strings.HasPrefix(dep.Name, collapsed[n-1].Name+"/")) {
+			continue
+		}
+		collapsed = append(collapsed, dep)
+	}
+	return collapsed
+}
+
+func importGodep(dir string) (*Manifest, error) {
+	godeps := struct {
+		ImportPath string
+		Deps       []struct {
+			ImportPath string
+			Rev        string
+		}
+	}{}
+	if _, err := readImported(dir, "Godeps/Godeps.json", &godeps, json.Unmarshal); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Name: godeps.ImportPath}
+	for _, dep := range godeps.Deps {
+		manifest.Dependencies = append(manifest.Dependencies, Dependency{Name: dep.ImportPath, Version: dep.Rev})
+	}
+	manifest.Dependencies = collapsePackages(manifest.Dependencies)
+	return manifest, nil
+}
+
+func importGovendor(dir string) (*Manifest, error) {
+	vendor := struct {
+		RootPath string
+		Package  []struct {
+			Path     string
+			Origin   string
+			Revision string
+		}
+	}{}
+	if _, err := readImported(dir, "vendor/vendor.json", &vendor, json.Unmarshal); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Name: vendor.RootPath}
+	for _, pkg := range vendor.Package {
+		// origin is where package was copied from when forked
+		name := pkg.Path
+		if pkg.Origin != "" {
+			name = pkg.Origin
+		}
+		manifest.Dependencies = append(manifest.Dependencies, Dependency{Name: name, Version: pkg.Revision})
+	}
+	manifest.Dependencies = collapsePackages(manifest.Dependencies)
+	return manifest, nil
+}
+
+func importGoMod(dir string) (*Manifest, error) {
+	mod, err := ReadGoMod(dir)
+	if err != nil {
+		return nil, err
+	}
+	return mod.Manifest(), nil
+}
+
+type glideImport struct {
+	Package string
+	Name    string
+	Version string
+	Repo    string
+	Vcs     string
+}
+
+func (g glideImport) dependency() Dependency {
+	dep := Dependency{Name: g.Package, Version: g.Version}
+	if dep.Name == "" {
+		dep.Name = g.Name
+	}
+	if g.Repo != "" {
+		dep.Vcs, dep.Remote = g.Vcs, g.Repo
+		if dep.Vcs == "" {
+			dep.Vcs = importVcs(g.Repo, "")
+		}
+	}
+	return dep
+}
+
+// vcs of remote a tool recorded without naming vcs, told by remote url or
+// by form of locked revision, git when neither tells
+func importVcs(remote string, revision string) string {
+	if vcs := VcsForRemote(remote); vcs != nil {
+		return vcs.Name
+	}
+
+	switch {
+	case revision != "" && strings.Trim(revision, "0123456789") == "":
+		// subversion revisions are numbers
+		return VcsSvn.Name
+	case strings.Contains(revision, "@"):
+		// bazaar revision ids start with committer email
+		return VcsBzr.Name
+	}
+	return VcsGit.Name
+}
+
+func importGlide(dir string) (*Manifest, error) {
+	glide := struct {
+		Package    string
+		Import     []glideImport
+		TestImport []glideImport `yaml:"testImport"`
+	}{}
+	if _, err := readImported(dir, "glide.yaml", &glide, yaml.Unmarshal); err != nil {
+		return nil, err
+	}
+
+	lock := struct {
+		Imports     []glideImport
+		TestImports []glideImport `yaml:"testImports"`
+	}{}
+	locked, err := readImported(dir, "glide.lock", &lock, yaml.Unmarshal)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Name: glide.Package}
+	imports := append(glide.Import, glide.TestImport...)
+	if locked {
+		imports = append(lock.Imports, lock.TestImports...)
+	}
+	for _, imp := range imports {
+		manifest.Dependencies = append(manifest.Dependencies, imp.dependency())
+	}
+	return manifest, nil
+}
+
+func importDep(dir string) (*Manifest, error) {
+	manifest := &Manifest{}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "Gopkg.lock"))
+	if err == nil {
+		for _, project := range parseTomlTables(string(content))["projects"] {
+			dep := Dependency{Name: project["name"], Version: project["revision"]}
+			if project["source"] != "" {
+				dep.Vcs, dep.Remote = importVcs(project["source"], project["revision"]), project["source"]
+			}
+			manifest.Dependencies = append(manifest.Dependencies, dep)
+		}
+		return manifest, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if content, err = ioutil.ReadFile(filepath.Join(dir, "Gopkg.toml")); err != nil {
+		return nil, err
+	}
+	for _, constraint := range parseTomlTables(string(content))["constraint"] {
+		dep := Dependency{Name: constraint["name"]}
+		switch {
+		case constraint["revision"] != "":
+			dep.Version = constraint["revision"]
+		case constraint["branch"] != "":
+			dep.Version = constraint["branch"]
+		case constraint["version"] != "":
+			dep.Version = depVersion(constraint["version"])
+		}
+		if constraint["source"] != "" {
+			dep.Vcs, dep.Remote = importVcs(constraint["source"], constraint["revision"]), constraint["source"]
+		}
+		manifest.Dependencies = append(manifest.Dependencies, dep)
+	}
+	return manifest, nil
+}
+
+// dep reads bare version as caret range and = as exact version
+func depVersion(version string) string {
+	version = strings.TrimSpace(strings.Replace(version, ",", " ", -1))
+	switch {
+	case version == "":
+	case strings.HasPrefix(version, "="):
+		return strings.TrimSpace(version[1:])
+	case strings.ContainsAny(version[:1], "0123456789v"):
+		return "^" + version
+	}
+	return version
+}
+
+// read string keys of every array table, enough of toml for Gopkg files
+func parseTomlTables(content string) map[string][]map[string]string {
+	tables := map[string][]map[string]string{}
+
+	var table map[string]string
+	inArray := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if inArray {
+			inArray = !strings.HasSuffix(line, "]")
+			continue
+		}
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[["):
+			name := strings.TrimSpace(strings.Trim(line, "[]"))
+			table = map[string]string{}
+			tables[name] = append(tables[name], table)
+		case strings.HasPrefix(line, "["):
+			table = nil
+		default:
+			token := strings.SplitN(line, "=", 2)
+			if len(token) != 2 {
+				continue
+			}
+			key, value := strings.TrimSpace(token[0]), strings.TrimSpace(token[1])
+			if strings.HasPrefix(value, "[") {
+				inArray = !strings.HasSuffix(value, "]")
+				continue
+			}
+			if end := strings.Index(value[1:], `"`); strings.HasPrefix(value, `"`) && end >= 0 {
+				value = value[:end+2]
+			}
+			if unquoted, err := strconv.Unquote(value); err == nil && table != nil {
+				table[key] = unquoted
+			}
+		}
+	}
+	return tables
+}
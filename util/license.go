@@ -0,0 +1,162 @@
+package util
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	unknownLicense = "unknown"
+)
+
+/**
+ * License type, license found at root of a locked package
+ */
+type (
+	License struct {
+		Name     string
+		Revision string
+		Type     string
+		File     string
+	}
+
+	LicensePolicy struct {
+		Allow []string
+		Deny  []string
+	}
+)
+
+// phrases telling license apart, checked in order so variants come before
+// the license they mention
+var licenseClassifiers = []struct {
+	license string
+	phrases []string
+}{
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"AGPL", []string{"gnu affero general public license"}},
+	{"LGPL", []string{"gnu lesser general public license"}},
+	{"LGPL", []string{"gnu library general public license"}},
+	{"GPL", []string{"gnu general public license"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "endorse or promote products"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+}
+
+/**
+ * Classify license text, unknown when none of known licenses matches
+ *
+ * @param {string} text
+ * @return {string}
+ */
+func ClassifyLicense(text string) string {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+
+	for _, classifier := range licenseClassifiers {
+		matched := true
+		for _, phrase := range classifier.phrases {
+			if !strings.Contains(text, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return classifier.license
+		}
+	}
+	return unknownLicense
+}
+
+func isLicenseFile(name string) bool {
+	name = strings.ToLower(name)
+	for _, prefix := range []string{"license", "licence", "copying", "unlicense"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Find license of every package recorded in lock inside vendor dir, from
+ * LICENSE or COPYING files at its root. First recognized file wins, package
+ * without any has unknown license and no file
+ *
+ * @param {string} vendorDir
+ * @param {*Lock} lock
+ * @return {[]*License}
+ * @return {error}
+ */
+func ScanLicenses(vendorDir string, lock *Lock) ([]*License, error) {
+	licenses := []*License{}
+
+	for _, pkg := range lock.Packages {
+		dir := filepath.Join(vendorDir, filepath.FromSlash(pkg.Name))
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		license := &License{Name: pkg.Name, Revision: pkg.Revision, Type: unknownLicense}
+		for _, info := range infos {
+			if info.IsDir() || !isLicenseFile(info.Name()) {
+				continue
+			}
+
+			content, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if license.File == "" {
+				license.File = info.Name()
+			}
+			if kind := ClassifyLicense(string(content)); kind != unknownLicense {
+				license.Type, license.File = kind, info.Name()
+				break
+			}
+		}
+		licenses = append(licenses, license)
+	}
+
+	sort.Slice(licenses, func(i, j int) bool {
+		return licenses[i].Name < licenses[j].Name
+	})
+	return licenses, nil
+}
+
+/**
+ * Tell whether policy restricts anything
+ *
+ * @return {bool}
+ */
+func (p LicensePolicy) IsEmpty() bool {
+	return len(p.Allow) == 0 && len(p.Deny) == 0
+}
+
+/**
+ * Tell whether license is allowed, denied license never is and when allow
+ * list is given only licenses on it are
+ *
+ * @param {string} license
+ * @return {bool}
+ */
+func (p LicensePolicy) Allows(license string) bool {
+	for _, denied := range p.Deny {
+		if strings.EqualFold(denied, license) {
+			return false
+		}
+	}
+
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, allowed := range p.Allow {
+		if strings.EqualFold(allowed, license) {
+			return true
+		}
+	}
+	return false
+}
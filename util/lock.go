@@ -0,0 +1,257 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	LOCKFILE = "gopas.lock"
+)
+
+/**
+ * Lock type records dependencies as declared and exact revision of every
+ * repository resolved under vendor dir
+ */
+type (
+	LockedPackage struct {
+		Name     string
+		Vcs      string
+		Remote   string
+		Revision string
+	}
+
+	Lock struct {
+		Dependencies []string
+		Packages     []LockedPackage
+	}
+)
+
+/**
+ * Read lock file from dir, nil lock when file does not exist
+ *
+ * @param {string} dir
+ * @return {*Lock}
+ * @return {error}
+ */
+func ReadLock(dir string) (*Lock, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, LOCKFILE))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{}
+	if err = yaml.Unmarshal(content, lock); err != nil {
+		return nil, fmt.Errorf("%s: %s", LOCKFILE, err.Error())
+	}
+	return lock, nil
+}
+
+/**
+ * Scan vendor dir for repositories and modules downloaded from proxy and
+ * record their current revision. Repositories and modules nested inside
+ * another one are recorded on their own
+ *
+ * @param {string} vendorDir
+ * @param {[]Dependency} dependencies
+ * @return {*Lock}
+ * @return {error}
+ */
+func NewLock(vendorDir string, dependencies []Dependency) (*Lock, error) {
+	lock := &Lock{
+		Dependencies: []string{},
+		Packages:     []LockedPackage{},
+	}
+
+	for _, dep := range dependencies {
+		lock.Dependencies = append(lock.Dependencies, dep.String())
+	}
+	sort.Strings(lock.Dependencies)
+
+	err := filepath.Walk(vendorDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() || path == vendorDir {
+			return err
+		}
+		if isVcsMeta(fi.Name()) {
+			return filepath.SkipDir
+		}
+
+		if module := ReadProxyModule(path); module != nil {
+			lock.Packages = append(lock.Packages, LockedPackage{
+				Name:     module.Path,
+				Vcs:      PROXYVCS,
+				Remote:   module.Proxy,
+				Revision: module.Version,
+			})
+			return nil
+		}
+
+		vcs := VcsForDir(path)
+		if vcs == nil {
+			return nil
+		}
+
+		pkg := LockedPackage{
+			Vcs: vcs.Name,
+		}
+		if pkg.Name, err = filepath.Rel(vendorDir, path); err != nil {
+			return err
+		}
+		pkg.Name = filepath.ToSlash(pkg.Name)
+		if pkg.Revision, err = vcs.Revision(path); err != nil {
+			return fmt.Errorf("%s: %s", pkg.Name, err.Error())
+		}
+		if pkg.Remote, err = vcs.Remote(path); err != nil {
+			return fmt.Errorf("%s: %s", pkg.Name, err.Error())
+		}
+		lock.Packages = append(lock.Packages, pkg)
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return lock, err
+}
+
+/**
+ * Write lock file into dir
+ *
+ * @param {string} dir
+ * @return {error}
+ */
+func (l *Lock) Write(dir string) error {
+	content, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, LOCKFILE), content, 0644)
+}
+
+/**
+ * Check whether lock was written for the same declared dependencies
+ *
+ * @param {[]Dependency} dependencies
+ * @return {bool}
+ */
+func (l *Lock) Matches(dependencies []Dependency) bool {
+	if len(l.Dependencies) != len(dependencies) {
+		return false
+	}
+
+	declared := map[string]bool{}
+	for _, dep := range l.Dependencies {
+		declared[dep] = true
+	}
+
+	for _, dep := range dependencies {
+		if !declared[dep.String()] {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * Find locked repository containing package name
+ *
+ * @param {string} name
+ * @return {*LockedPackage}
+ */
+func (l *Lock) Package(name string) *LockedPackage {
+	var found *LockedPackage
+	for i, pkg := range l.Packages {
+		if (pkg.Name == name || strings.HasPrefix(name, pkg.Name+"/")) && (found == nil || len(pkg.Name) > len(found.Name)) {
+			found = &l.Packages[i]
+		}
+	}
+	return found
+}
+
+/**
+ * RestoreError type, locked packages that failed to restore and why
+ */
+type RestoreError struct {
+	Packages []LockedPackage
+	Errors   []error
+	Total    int
+}
+
+func (e *RestoreError) Error() string {
+	return fmt.Sprintf("%d of %d locked packages failed to restore", len(e.Packages), e.Total)
+}
+
+/**
+ * Checkout every locked package inside vendor dir, creating repositories
+ * that do not exist yet from cache or their remote. Modules locked from
+ * proxy are downloaded again when another version is unpacked. Package
+ * failing does not stop the rest, *RestoreError lists every failure
+ *
+ * @param {string} vendorDir
+ * @param {*Cache} cache
+ * @return {error}
+ */
+func (l *Lock) Restore(vendorDir string, cache *Cache) error {
+	failed := &RestoreError{Total: len(l.Packages)}
+	for _, pkg := range l.Packages {
+		if err := restorePackage(vendorDir, pkg, cache); err != nil {
+			failed.Packages = append(failed.Packages, pkg)
+			failed.Errors = append(failed.Errors, err)
+		}
+	}
+
+	if len(failed.Packages) > 0 {
+		return failed
+	}
+	return nil
+}
+
+func restorePackage(vendorDir string, pkg LockedPackage, cache *Cache) error {
+	if pkg.Vcs == PROXYVCS {
+		return restoreModule(vendorDir, pkg, cache)
+	}
+
+	vcs := VcsByName(pkg.Vcs)
+	if vcs == nil {
+		return fmt.Errorf("unknown vcs %s", pkg.Vcs)
+	}
+
+	if pkg.Revision == "" {
+		return errors.New("revision is undefined")
+	}
+
+	dir := filepath.Join(vendorDir, filepath.FromSlash(pkg.Name))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if cache != nil {
+			err = cache.Create(pkg.Name, vcs, pkg.Remote, dir)
+		} else {
+			err = vcs.Create(pkg.Remote, dir)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return cache.Checkout(vcs, pkg.Name, dir, pkg.Revision)
+}
+
+func restoreModule(vendorDir string, pkg LockedPackage, cache *Cache) error {
+	dir := filepath.Join(vendorDir, filepath.FromSlash(pkg.Name))
+	if current := ReadProxyModule(dir); current != nil && current.Version == pkg.Revision {
+		return nil
+	}
+
+	if cache != nil && cache.Offline {
+		return cache.missError(pkg.Name + "@" + pkg.Revision)
+	}
+	return NewProxy(pkg.Remote).Download(pkg.Name, pkg.Revision, dir)
+}
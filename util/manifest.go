@@ -0,0 +1,488 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	GOPASYML = "gopas.yml"
+)
+
+var (
+	localDependencyRe = regexp.MustCompile(`^\s*(\S+)\s+=>\s*(.*?)\s*$`)
+	yamlKeyRe         = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.*?)\s*$`)
+	yamlItemRe        = regexp.MustCompile(`^(\s*)-\s+(.*?)\s*$`)
+)
+
+/**
+ * Manifest type, project configuration read from gopas.yml or legacy
+ * gopasfile
+ */
+type Manifest struct {
+	Name         string
+	PreBuild     [][]string
+	Dependencies []Dependency
+	Licenses     LicensePolicy
+	Ignore       []string
+	Layout       string
+}
+
+/**
+ * Parse dependency written as name, name=version or name => local/path
+ *
+ * @param {string} s
+ * @return {Dependency}
+ */
+func ParseDependency(s string) Dependency {
+	// path needs space before arrow, name=>=1.0 is a version range
+	if match := localDependencyRe.FindStringSubmatch(s); match != nil {
+		return Dependency{
+			Name: match[1],
+			Path: match[2],
+		}
+	}
+
+	token := strings.SplitN(s, "=", 2)
+	dependency := Dependency{
+		Name: strings.Trim(token[0], " \t"),
+	}
+	if len(token) > 1 {
+		dependency.Version = strings.Trim(token[1], " \t")
+	}
+	return dependency
+}
+
+/**
+ * Unmarshal dependency item of gopas.yml, either string form parsed by
+ * ParseDependency or map with name, version, path and at most one of git,
+ * hg, bzr or svn remote to clone from
+ *
+ * @param {func(interface{}) error} unmarshal
+ * @return {error}
+ */
+func (d *Dependency) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*d = ParseDependency(s)
+		return nil
+	}
+
+	item := struct {
+		Name    string
+		Version string
+		Path    string
+		Git     string
+		Hg      string
+		Bzr     string
+		Svn     string
+	}{}
+	if err := unmarshal(&item); err != nil {
+		return err
+	}
+
+	if item.Name == "" {
+		return errors.New("Dependency name is undefined")
+	}
+	*d = Dependency{
+		Name:    item.Name,
+		Version: item.Version,
+		Path:    item.Path,
+	}
+
+	for vcs, remote := range map[string]string{"git": item.Git, "hg": item.Hg, "bzr": item.Bzr, "svn": item.Svn} {
+		if remote == "" {
+			continue
+		}
+		if d.Remote != "" {
+			return fmt.Errorf("%s: only one of git, hg, bzr or svn allowed", d.Name)
+		}
+		d.Vcs, d.Remote = vcs, remote
+	}
+
+	if d.Remote != "" && d.Path != "" {
+		return fmt.Errorf("%s: path and remote are exclusive", d.Name)
+	}
+	return nil
+}
+
+/**
+ * Read manifest of project at dir, dependencies fallback to gopasfile when
+ * gopas.yml does not declare any
+ *
+ * @param {string} dir
+ * @return {*Manifest}
+ * @return {error}
+ */
+func ReadManifest(dir string) (*Manifest, error) {
+	manifest := &Manifest{}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, GOPASYML))
+	if err == nil {
+		config := struct {
+			Name         string
+			PreBuild     [][]string `yaml:"pre-build"`
+			Dependencies []Dependency
+			Licenses     LicensePolicy
+			Ignore       []string
+			Layout       string
+		}{}
+		if err = yaml.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("%s: %s", GOPASYML, err.Error())
+		}
+
+		manifest.Name = config.Name
+		manifest.PreBuild = config.PreBuild
+		manifest.Dependencies = config.Dependencies
+		manifest.Licenses = config.Licenses
+		manifest.Ignore = config.Ignore
+		manifest.Layout = config.Layout
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(manifest.Dependencies) == 0 {
+		if content, err = ioutil.ReadFile(filepath.Join(dir, GOPASFILE)); err == nil {
+			for _, line := range strings.Split(string(content), "\n") {
+				if strings.Trim(line, " \t\r") != "" {
+					manifest.Dependencies = append(manifest.Dependencies, ParseDependency(strings.TrimRight(line, "\r")))
+				}
+			}
+		}
+	}
+
+	return manifest, nil
+}
+
+/**
+ * Write manifest as new gopas.yml of project at dir
+ *
+ * @param {string} dir
+ * @param {*Manifest} manifest
+ * @return {error}
+ */
+func WriteManifest(dir string, manifest *Manifest) error {
+	lines := []string{}
+	if manifest.Name != "" {
+		lines = append(lines, "name: "+yamlScalar(manifest.Name), "")
+	}
+	if manifest.Layout != "" {
+		lines = append(lines, "layout: "+yamlScalar(manifest.Layout), "")
+	}
+
+	if len(manifest.PreBuild) > 0 {
+		lines = append(lines, "pre-build:")
+		for _, cmd := range manifest.PreBuild {
+			quoted := []string{}
+			for _, arg := range cmd {
+				quoted = append(quoted, strconv.Quote(arg))
+			}
+			lines = append(lines, "  - ["+strings.Join(quoted, ", ")+"]")
+		}
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, "dependencies:")
+	for _, dep := range manifest.Dependencies {
+		if dep.Remote == "" {
+			lines = append(lines, "  - "+yamlScalar(dep.String()))
+			continue
+		}
+
+		lines = append(lines, "  - name: "+yamlScalar(dep.Name))
+		if dep.Version != "" {
+			lines = append(lines, "    version: "+yamlScalar(dep.Version))
+		}
+		lines = append(lines, "    "+dep.Vcs+": "+yamlScalar(dep.Remote))
+	}
+
+	if !manifest.Licenses.IsEmpty() {
+		lines = append(lines, "", "licenses:")
+		for _, list := range []struct {
+			key      string
+			licenses []string
+		}{{"allow", manifest.Licenses.Allow}, {"deny", manifest.Licenses.Deny}} {
+			if len(list.licenses) == 0 {
+				continue
+			}
+			lines = append(lines, "  "+list.key+":")
+			for _, license := range list.licenses {
+				lines = append(lines, "    - "+yamlScalar(license))
+			}
+		}
+	}
+
+	if len(manifest.Ignore) > 0 {
+		lines = append(lines, "", "ignore:")
+		for _, ignore := range manifest.Ignore {
+			lines = append(lines, "  - "+yamlScalar(ignore))
+		}
+	}
+
+	return writeManifestLines(dir, GOPASYML, lines)
+}
+
+/**
+ * Add dependency to manifest of project at dir, editing gopas.yml or legacy
+ * gopasfile in place, whichever declares dependencies
+ *
+ * @param {string} dir
+ * @param {Dependency} dependency
+ * @return {error}
+ */
+func AddDependency(dir string, dependency Dependency) error {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, dep := range manifest.Dependencies {
+		if dep.Name == dependency.Name {
+			return fmt.Errorf("%s is already a dependency", dependency.Name)
+		}
+	}
+
+	file, lines, err := readManifestLines(dir)
+	if err != nil {
+		return err
+	}
+
+	if file == GOPASFILE {
+		lines = append(trimTrailingEmpty(lines), dependency.String())
+		return writeManifestLines(dir, file, lines)
+	}
+
+	start, end, indent, err := findYamlDependencies(lines)
+	if err != nil {
+		return err
+	}
+
+	item := indent + "- " + yamlScalar(dependency.String())
+	if start < 0 {
+		lines = append(trimTrailingEmpty(lines), "", "dependencies:", item)
+	} else {
+		lines = append(lines[:end], append([]string{item}, lines[end:]...)...)
+	}
+	return writeManifestLines(dir, file, lines)
+}
+
+/**
+ * Remove dependency by name from manifest of project at dir
+ *
+ * @param {string} dir
+ * @param {string} name
+ * @return {error}
+ */
+func RemoveDependency(dir string, name string) error {
+	file, lines, err := readManifestLines(dir)
+	if err != nil {
+		return err
+	}
+
+	start, end, _, err := findManifestDependency(file, lines, name)
+	if err != nil {
+		return err
+	}
+
+	lines = append(lines[:start], lines[end:]...)
+	return writeManifestLines(dir, file, lines)
+}
+
+/**
+ * Set version of dependency in manifest of project at dir, editing its
+ * item in place
+ *
+ * @param {string} dir
+ * @param {string} name
+ * @param {string} version
+ * @return {error}
+ */
+func SetDependencyVersion(dir string, name string, version string) error {
+	file, lines, err := readManifestLines(dir)
+	if err != nil {
+		return err
+	}
+
+	start, end, dependency, err := findManifestDependency(file, lines, name)
+	if err != nil {
+		return err
+	}
+	dependency.Version = version
+
+	item := []string{dependency.String()}
+	if file == GOPASYML {
+		indent := yamlItemRe.FindStringSubmatch(lines[start])[1]
+		if dependency.Remote == "" {
+			comment := strings.TrimPrefix(lines[start], stripYamlComment(lines[start]))
+			item = []string{indent + "- " + yamlScalar(dependency.String()) + comment}
+		} else {
+			item = []string{indent + "- name: " + yamlScalar(dependency.Name)}
+			if dependency.Version != "" {
+				item = append(item, indent+"  version: "+yamlScalar(dependency.Version))
+			}
+			item = append(item, indent+"  "+dependency.Vcs+": "+yamlScalar(dependency.Remote))
+		}
+	}
+
+	lines = append(lines[:start], append(item, lines[end:]...)...)
+	return writeManifestLines(dir, file, lines)
+}
+
+// find lines of dependency item by name, end is index after its last line
+func findManifestDependency(file string, lines []string, name string) (int, int, Dependency, error) {
+	start, end := 0, len(lines)
+	if file == GOPASYML {
+		var err error
+		if start, end, _, err = findYamlDependencies(lines); err != nil {
+			return 0, 0, Dependency{}, err
+		}
+	}
+
+	for i := start; i >= 0 && i < end; i++ {
+		next := i + 1
+		dependency := ParseDependency(lines[i])
+		if file == GOPASYML {
+			match := yamlItemRe.FindStringSubmatch(stripYamlComment(lines[i]))
+			if match == nil {
+				continue
+			}
+
+			// map item spans the lines indented deeper than its dash
+			for next < end && yamlContinues(lines[next], match[1]) {
+				next++
+			}
+			items := []Dependency{}
+			if err := yaml.Unmarshal([]byte(strings.Join(lines[i:next], "\n")), &items); err != nil || len(items) != 1 {
+				continue
+			}
+			dependency = items[0]
+		}
+
+		if strings.TrimSpace(lines[i]) != "" && dependency.Name == name {
+			return i, next, dependency, nil
+		}
+	}
+
+	return 0, 0, Dependency{}, fmt.Errorf("%s is not a dependency", name)
+}
+
+// pick file declaring dependencies the same way ReadManifest does, new
+// gopas.yml when none exists
+func readManifestLines(dir string) (string, []string, error) {
+	file := GOPASYML
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, GOPASYML))
+	if err != nil && !os.IsNotExist(err) {
+		return "", nil, err
+	}
+
+	config := struct {
+		Dependencies []interface{}
+	}{}
+	if err = yaml.Unmarshal(content, &config); err != nil {
+		return "", nil, fmt.Errorf("%s: %s", GOPASYML, err.Error())
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, GOPASFILE)); err == nil && len(config.Dependencies) == 0 {
+		file = GOPASFILE
+	}
+
+	content, err = ioutil.ReadFile(filepath.Join(dir, file))
+	if os.IsNotExist(err) {
+		return file, []string{}, nil
+	} else if err != nil {
+		return "", nil, err
+	}
+
+	return file, strings.Split(strings.TrimRight(string(content), "\n"), "\n"), nil
+}
+
+func writeManifestLines(dir string, file string, lines []string) error {
+	content := strings.Join(lines, "\n") + "\n"
+	return ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644)
+}
+
+// find item lines of top level dependencies key, start is -1 when key does
+// not exist, end is index to insert next item at
+func findYamlDependencies(lines []string) (int, int, string, error) {
+	start, end, indent := -1, -1, "  "
+
+	for i, line := range lines {
+		match := yamlKeyRe.FindStringSubmatch(stripYamlComment(line))
+		if start < 0 {
+			if match == nil || match[1] != "dependencies" {
+				continue
+			}
+			switch match[2] {
+			case "":
+			case "[]":
+				lines[i] = "dependencies:"
+			default:
+				return 0, 0, "", errors.New("Inline dependencies list is not supported")
+			}
+			start, end = i+1, i+1
+			continue
+		}
+
+		if match != nil {
+			break
+		}
+
+		if item := yamlItemRe.FindStringSubmatch(line); item != nil {
+			indent = item[1]
+			end = i + 1
+		} else if end > start && yamlContinues(line, indent) {
+			end = i + 1
+		}
+	}
+
+	return start, end, indent, nil
+}
+
+// check whether line still belongs to map item whose dash is at indent
+func yamlContinues(line string, indent string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#") && len(line)-len(trimmed) > len(indent)
+}
+
+func stripYamlComment(line string) string {
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return ""
+	}
+	if i := strings.Index(line, " #"); i >= 0 && !strings.ContainsAny(line[:i], `"'`) {
+		return line[:i]
+	}
+	return line
+}
+
+func yamlScalar(s string) string {
+	if s == "" {
+		return `""`
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.ContainsAny(s[:1], `"'&*!|>%@{}[],`) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func yamlUnquote(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil && strings.HasPrefix(s, `"`) {
+		return unquoted
+	}
+	return strings.Trim(s, "'")
+}
+
+func trimTrailingEmpty(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
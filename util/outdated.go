@@ -0,0 +1,161 @@
+package util
+
+import (
+	"path/filepath"
+)
+
+/**
+ * Outdated type reports revision of vendored dependency against what its
+ * version allows and newest tag of its repository
+ */
+type Outdated struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Current  string `json:"current"`
+	Revision string `json:"revision"`
+	Wanted   string `json:"wanted"`
+	Latest   string `json:"latest"`
+	Outdated bool   `json:"outdated"`
+}
+
+/**
+ * Inspect local checkout of dependency inside vendor dir, nothing is
+ * downloaded so result is as new as the last install or update. Module
+ * downloaded from proxy is compared with versions the proxy lists instead,
+ * offline its wanted and latest version are reported unknown
+ *
+ * @param {string} vendorDir
+ * @param {Dependency} dependency
+ * @param {bool} offline
+ * @return {*Outdated}
+ * @return {error}
+ */
+func CheckOutdated(vendorDir string, dependency Dependency, offline bool) (*Outdated, error) {
+	var (
+		root   string
+		tags   []string
+		wanted string
+		vcs    *Vcs
+		err    error
+	)
+
+	o := &Outdated{
+		Name:    dependency.Name,
+		Version: dependency.Version,
+	}
+
+	if dependency.Path != "" {
+		o.Current, o.Wanted = dependency.Path, dependency.Path
+		return o, nil
+	}
+
+	if _, module := FindProxyModule(vendorDir, dependency.Name); module != nil {
+		return checkOutdatedModule(o, dependency, module, offline), nil
+	}
+
+	if root, vcs, err = FindRepoRoot(vendorDir, filepath.Join(vendorDir, dependency.Name)); err != nil {
+		// not installed yet
+		return o, nil
+	}
+
+	if o.Revision, err = vcs.Revision(root); err != nil {
+		return nil, err
+	}
+	if o.Current = vcs.Tag(root); o.Current == "" {
+		o.Current = ShortRevision(o.Revision)
+	}
+
+	// without tags latest is unknown, wanted may still resolve
+	tags, _ = vcs.Tags(root)
+	o.Latest = LatestVersion(tags)
+
+	if IsConstraint(dependency.Version) {
+		requirements := []Requirement{{Version: dependency.Version, Chain: []string{dependency.Name}}}
+		if o.Wanted, err = vcs.SelectVersion(root, dependency.Name, requirements); err != nil {
+			// nothing matches or vcs has no tags, report without wanted version
+			return o, nil
+		}
+		wanted, err = vcs.Resolve(root, o.Wanted)
+	} else {
+		if wanted, err = vcs.Resolve(root, dependency.Version); err == nil {
+			o.Wanted = ShortRevision(wanted)
+			for _, tag := range tags {
+				if tag == dependency.Version {
+					o.Wanted = tag
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		// e.g. no origin/HEAD to tell default branch, report wanted unknown
+		o.Wanted = ""
+		return o, nil
+	}
+
+	o.Outdated = wanted != o.Revision
+	return o, nil
+}
+
+// compare module downloaded from proxy with versions the proxy lists, what
+// proxy can not tell or offline can not ask is reported unknown
+func checkOutdatedModule(o *Outdated, dependency Dependency, module *ProxyModule, offline bool) *Outdated {
+	o.Current, o.Revision = module.Version, module.Version
+	if offline {
+		return o
+	}
+
+	proxy := NewProxy(module.Proxy)
+	versions, err := proxy.List(module.Path)
+	if err != nil {
+		return o
+	}
+	o.Latest = LatestVersion(versions)
+
+	if IsConstraint(dependency.Version) {
+		requirements := []Requirement{{Version: dependency.Version, Chain: []string{dependency.Name}}}
+		o.Wanted, err = SelectVersion(module.Path, versions, requirements)
+	} else {
+		o.Wanted, err = proxy.Resolve(module.Path, versions, dependency.Version)
+	}
+	if err != nil {
+		o.Wanted = ""
+		return o
+	}
+
+	o.Outdated = o.Wanted != o.Revision
+	return o
+}
+
+/**
+ * Find highest non prerelease semver tag
+ *
+ * @param {[]string} tags
+ * @return {string}
+ */
+func LatestVersion(tags []string) string {
+	var latest *Version
+	for _, tag := range tags {
+		if v, err := ParseVersion(tag); err == nil && v.Pre == "" && (latest == nil || v.Compare(latest) > 0) {
+			latest = v
+		}
+	}
+
+	if latest == nil {
+		return ""
+	}
+	return latest.Original
+}
+
+/**
+ * Shorten revision hash for display
+ *
+ * @param {string} revision
+ * @return {string}
+ */
+func ShortRevision(revision string) string {
+	if len(revision) > 12 {
+		return revision[:12]
+	}
+	return revision
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"sync"
+)
+
+/**
+ * Run job for every index below count with at most workers jobs at the
+ * same time. Done is called from the calling goroutine as each job
+ * finishes, so it may log or collect results without locking
+ *
+ * @param {int} workers
+ * @param {int} count
+ * @param {func(int) error} job
+ * @param {func(int, error)} done
+ */
+func RunPool(workers int, count int, job func(i int) error, done func(i int, err error)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	type result struct {
+		i   int
+		err error
+	}
+
+	queue := make(chan int)
+	results := make(chan result)
+
+	for w := 0; w < workers && w < count; w++ {
+		go func() {
+			for i := range queue {
+				results <- result{i, job(i)}
+			}
+		}()
+	}
+
+	go func() {
+		for i := 0; i < count; i++ {
+			queue <- i
+		}
+		close(queue)
+	}()
+
+	for n := 0; n < count; n++ {
+		r := <-results
+		done(r.i, r.err)
+	}
+}
+
+/**
+ * KeyedMutex type, one mutex per key created on demand
+ */
+type KeyedMutex struct {
+	mutex sync.Mutex
+	keys  map[string]*sync.Mutex
+}
+
+/**
+ * Lock key, returning function unlocking it
+ *
+ * @param {string} key
+ * @return {func()}
+ */
+func (m *KeyedMutex) Lock(key string) func() {
+	m.mutex.Lock()
+	if m.keys == nil {
+		m.keys = map[string]*sync.Mutex{}
+	}
+	mutex, ok := m.keys[key]
+	if !ok {
+		mutex = &sync.Mutex{}
+		m.keys[key] = mutex
+	}
+	m.mutex.Unlock()
+
+	mutex.Lock()
+	return mutex.Unlock
+}
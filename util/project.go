@@ -1,20 +1,23 @@
 package util
 
 import (
+	"errors"
 	"fmt"
+	"go/build"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
-
-	"gopkg.in/yaml.v2"
 )
 
 const (
-	GOPASFILE = "gopasfile"
+	GOPASFILE     = "gopasfile"
+	LAYOUTCOPY    = "copy"
+	LAYOUTSYMLINK = "symlink"
 )
 
 /**
@@ -24,12 +27,38 @@ type (
 	Dependency struct {
 		Name    string
 		Version string
+		Path    string
+		Vcs     string
+		Remote  string
 	}
 
 	Project interface {
 		Dependencies() []Dependency
 		Clean() error
 		Get(dependency Dependency) error
+		Update(dependency Dependency) (string, error)
+		Outdated(dependency Dependency) (*Outdated, error)
+		Graph() (*ImportGraph, error)
+		FullGraph() (*ImportGraph, error)
+		CheckDependencies(graph *ImportGraph) *DepsReport
+		AddDependency(dependency Dependency) error
+		RemoveDependency(name string) error
+		SetDependencyVersion(name string, version string) error
+		ImportManifest(source string) (*Manifest, string, error)
+		WriteManifest(manifest *Manifest, overwrite bool) error
+		ExportGoMod(overwrite bool) error
+		Lock() (*Lock, error)
+		Scan() (*Lock, error)
+		Restore(lock *Lock) error
+		WriteLock() error
+		Verify() error
+		Licenses() ([]*License, error)
+		LicensePolicy() LicensePolicy
+		Audit(advisories string) ([]*AuditFinding, []string, error)
+		Prune(dryRun bool) ([]*PrunedPackage, error)
+		Ignore() (*Ignore, error)
+		Index() error
+		Search(query string) ([]*SearchResult, error)
 		Run(args ...string) error
 		Test(cover bool, packages ...string) error
 		PreBuild() error
@@ -37,20 +66,49 @@ type (
 
 		Name() string
 		Dir() string
+		IsModule() bool
 		GoRun(args ...string) error
 	}
 
 	ProjectImpl struct {
 		*Logger
+		Cache        *Cache
+		Config       *Config
 		Cwd          string
 		name         string
 		gopaths      []string
 		preBuild     [][]string
 		dependencies []Dependency
+		licenses     LicensePolicy
+		ignores      []string
+		layout       string
+		requirements map[string][]Requirement
+		resolved     map[string]string
 		exeGo        string
+		module       bool
+
+		// guards requirements and resolved while dependencies are got in
+		// parallel, repos serializes work on the same repository
+		mutex sync.Mutex
+		repos KeyedMutex
 	}
 )
 
+func (d Dependency) String() string {
+	if d.Path != "" {
+		return d.Name + " => " + d.Path
+	}
+
+	s := d.Name
+	if d.Version != "" {
+		s += "=" + d.Version
+	}
+	if d.Remote != "" {
+		s += " (" + d.Vcs + " " + d.Remote + ")"
+	}
+	return s
+}
+
 func (p *ProjectImpl) Gopath() []string {
 	if len(p.gopaths) == 0 {
 		p.gopaths = []string{
@@ -63,15 +121,41 @@ func (p *ProjectImpl) Gopath() []string {
 }
 
 func (p *ProjectImpl) Env() []string {
+	if p.module {
+		// binaries still go where Run looks for them
+		return []string{
+			"GO111MODULE=on",
+			"GOBIN=" + filepath.Join(p.Gopath()[0], "bin"),
+		}
+	}
+
+	// go defaults to module mode, gopath needs to be asked for
 	return []string{
+		"GO111MODULE=off",
 		"GOPATH=" + strings.Join(p.Gopath(), ":"),
 	}
 }
 
+func (p *ProjectImpl) VendorDir() string {
+	return filepath.Join(p.Gopath()[1], "src")
+}
+
 func (p *ProjectImpl) Dir() string {
+	if p.module {
+		return p.Cwd
+	}
 	return filepath.Join(p.Gopath()[0], "src", p.Name())
 }
 
+/**
+ * Tell whether project has go.mod, so it is built in module mode from its
+ * own dir instead of a gopath copy
+ */
+func (p *ProjectImpl) IsModule() bool {
+	p.Bootstrap()
+	return p.module
+}
+
 func (p *ProjectImpl) Name() string {
 	if p.name == "" {
 		p.name = filepath.Base(p.Cwd)
@@ -83,29 +167,830 @@ func (p *ProjectImpl) Dependencies() []Dependency {
 	p.Bootstrap()
 	if p.dependencies == nil {
 		p.dependencies = []Dependency{}
-		if fileBytes, err := ioutil.ReadFile(filepath.Join(p.Cwd, GOPASFILE)); err == nil {
-			fileLines := strings.Split(string(fileBytes), "\n")
-			for _, line := range fileLines {
-				if line != "" {
-					token := strings.Split(line, "=")
-					name := strings.Trim(token[0], " \t")
-					version := ""
-					if len(token) > 1 {
-						version = strings.Trim(token[1], " \t")
-					}
-					p.dependencies = append(p.dependencies, Dependency{
-						Name:    name,
-						Version: version,
-					})
+	}
+	return p.dependencies
+}
+
+/**
+ * Get dependency into vendor dir, version may be a tag, branch or commit to
+ * checkout, or a range resolved to the highest matching tag. Dependencies
+ * declared by fetched package are got recursively, so requirements on the
+ * same repository from different chains are checked against each other
+ */
+func (p *ProjectImpl) Get(dependency Dependency) error {
+	if err := p.Bootstrap(); err != nil {
+		return err
+	}
+	return p.get(dependency, []string{p.Name()})
+}
+
+func (p *ProjectImpl) get(dependency Dependency, chain []string) error {
+	var (
+		root    string
+		repo    string
+		version string
+		vcs     *Vcs
+		err     error
+	)
+
+	chain = append(chain[:len(chain):len(chain)], dependency.String())
+
+	if dependency.Path != "" {
+		return p.getLocal(dependency, chain)
+	}
+
+	if proxy := p.proxy(dependency.Name); proxy != nil && dependency.Remote == "" {
+		return p.getModule(proxy, dependency, chain)
+	}
+
+	if dependency.Remote != "" {
+		err = p.clone(dependency.Name, VcsByName(dependency.Vcs), dependency.Remote, dependency.Name)
+	} else {
+		err = p.fetch(dependency.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if root, vcs, err = FindRepoRoot(p.VendorDir(), filepath.Join(p.VendorDir(), dependency.Name)); err != nil {
+		return err
+	}
+	if repo, err = filepath.Rel(p.VendorDir(), root); err != nil {
+		return err
+	}
+	repo = filepath.ToSlash(repo)
+
+	unlock := p.repos.Lock(repo)
+	requirements := p.require(repo, Requirement{Version: dependency.Version, Chain: chain})
+
+	version, err = vcs.SelectVersion(root, repo, requirements)
+	if err == nil && version != "" {
+		if err = p.Cache.Checkout(vcs, repo, root, version); err != nil {
+			err = fmt.Errorf("%s@%s: %s", dependency.Name, version, err.Error())
+		}
+	}
+	unlock()
+
+	if err != nil {
+		return err
+	}
+	return p.getRequired(dependency, version, root, chain)
+}
+
+// add requirement on repository, returning every requirement on it so far
+func (p *ProjectImpl) require(repo string, requirement Requirement) []Requirement {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.requirements == nil {
+		p.requirements = map[string][]Requirement{}
+	}
+	p.requirements[repo] = append(p.requirements[repo], requirement)
+	return append([]Requirement{}, p.requirements[repo]...)
+}
+
+// remember version dependency resolved to, false when it already was
+func (p *ProjectImpl) resolve(name string, version string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.resolved == nil {
+		p.resolved = map[string]string{}
+	}
+	if resolved, ok := p.resolved[name]; ok && resolved == version {
+		return false
+	}
+	p.resolved[name] = version
+	return true
+}
+
+// get dependencies declared by manifest of dependency and install it,
+// unless it was done for the same version already
+func (p *ProjectImpl) getRequired(dependency Dependency, version string, root string, chain []string) error {
+	var (
+		manifest *Manifest
+		err      error
+	)
+
+	if !p.resolve(dependency.Name, version) {
+		return nil
+	}
+
+	if manifest, err = ReadManifest(filepath.Join(p.VendorDir(), dependency.Name)); err != nil {
+		return err
+	}
+	if len(manifest.Dependencies) == 0 {
+		if manifest, err = ReadManifest(root); err != nil {
+			return err
+		}
+	}
+
+	for _, dep := range manifest.Dependencies {
+		if err = p.get(dep, chain); err != nil {
+			return err
+		}
+	}
+
+	return p.vendorRun("install", dependency.Name)
+}
+
+/**
+ * Get dependency from module proxy, version selection works the same as
+ * with tags of a repository
+ */
+func (p *ProjectImpl) getModule(proxy *Proxy, dependency Dependency, chain []string) error {
+	var (
+		module   string
+		versions []string
+		err      error
+	)
+
+	if p.Cache.Offline {
+		_, current := FindProxyModule(p.VendorDir(), dependency.Name)
+		if current == nil {
+			return p.Cache.missError(dependency.Name)
+		}
+		module, versions = current.Path, []string{current.Version}
+	} else if module, versions, err = proxy.FindModule(dependency.Name); err != nil {
+		return err
+	}
+
+	root := filepath.Join(p.VendorDir(), filepath.FromSlash(module))
+	unlock := p.repos.Lock(module)
+	version, err := p.downloadModule(proxy, module, versions, root, Requirement{Version: dependency.Version, Chain: chain})
+	unlock()
+	if err != nil {
+		return fmt.Errorf("%s@%s: %s", dependency.Name, dependency.Version, err.Error())
+	}
+
+	if err = p.fetchImports(dependency.Name); err != nil {
+		return err
+	}
+	return p.getRequired(dependency, version, root, chain)
+}
+
+// select version of module satisfying every requirement on it, then
+// download it into root unless it is there already
+func (p *ProjectImpl) downloadModule(proxy *Proxy, module string, versions []string, root string, requirement Requirement) (string, error) {
+	version, err := SelectVersion(module, versions, p.require(module, requirement))
+	if err != nil {
+		return "", err
+	}
+
+	current := ReadProxyModule(root)
+	if p.Cache.Offline {
+		if current == nil || (version != "" && version != current.Version) {
+			return "", p.Cache.missError(module + "@" + version)
+		}
+		return current.Version, nil
+	}
+
+	if version, err = proxy.Resolve(module, versions, version); err != nil {
+		return "", err
+	}
+	if current == nil || current.Path != module || current.Version != version {
+		err = proxy.Download(module, version, root)
+	}
+	return version, err
+}
+
+/**
+ * Update fetched dependency to newest revision allowed by its version,
+ * highest matching tag for range or newest commit for branch. Returns
+ * version manifest should record, or empty when version stays as it is.
+ * See recordedVersion
+ */
+func (p *ProjectImpl) Update(dependency Dependency) (string, error) {
+	var (
+		root    string
+		repo    string
+		version string
+		vcs     *Vcs
+		err     error
+	)
+
+	if dependency.Path != "" {
+		// local dir is always as new as it gets
+		return "", nil
+	}
+
+	if err = p.Bootstrap(); err != nil {
+		return "", err
+	}
+	if root, vcs, err = FindRepoRoot(p.VendorDir(), filepath.Join(p.VendorDir(), dependency.Name)); err != nil {
+		// not fetched yet or downloaded from proxy, get selects newest
+		if err = p.Get(dependency); err != nil {
+			return "", err
+		}
+		return p.recordedVersion(dependency)
+	}
+	if repo, err = filepath.Rel(p.VendorDir(), root); err != nil {
+		return "", err
+	}
+
+	// cached mirror of another remote or vcs must not feed checkout
+	remote, _ := vcs.Remote(root)
+	if p.Cache.Mirrors(repo, vcs, remote) {
+		if err = p.Cache.Fetch(repo); err == nil {
+			err = p.Cache.Sync(repo, root)
+		}
+	} else if p.Cache.Offline {
+		err = p.Cache.missError(repo)
+	} else {
+		err = vcs.Download(root)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if IsConstraint(dependency.Version) {
+		requirements := []Requirement{{Version: dependency.Version, Chain: []string{p.Name(), dependency.String()}}}
+		if version, err = vcs.SelectVersion(root, filepath.ToSlash(repo), requirements); err != nil {
+			return "", err
+		}
+		err = p.Cache.Checkout(vcs, repo, root, version)
+	} else {
+		err = p.Cache.CheckoutUpstream(vcs, repo, root, dependency.Version)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("%s@%s: %s", dependency.Name, dependency.Version, err.Error())
+	}
+
+	if err = p.vendorRun("install", dependency.Name); err != nil {
+		return "", err
+	}
+	return p.recordedVersion(dependency)
+}
+
+// version manifest records for dependency as vendored now. ^, ~ or >= range
+// is raised to selected tag, any other range, branch or empty version is
+// replaced by selected tag, or revision when it has none. Empty when
+// version is recorded already, e.g. exact tag or revision
+func (p *ProjectImpl) recordedVersion(dependency Dependency) (string, error) {
+	var selected string
+
+	if _, module := FindProxyModule(p.VendorDir(), dependency.Name); module != nil {
+		selected = module.Version
+	} else {
+		root, vcs, err := FindRepoRoot(p.VendorDir(), filepath.Join(p.VendorDir(), dependency.Name))
+		if err != nil {
+			return "", err
+		}
+		revision, err := vcs.Revision(root)
+		if err != nil {
+			return "", err
+		}
+
+		if IsConstraint(dependency.Version) {
+			repo, _ := filepath.Rel(p.VendorDir(), root)
+			requirements := []Requirement{{Version: dependency.Version, Chain: []string{p.Name(), dependency.String()}}}
+			if selected, err = vcs.SelectVersion(root, filepath.ToSlash(repo), requirements); err != nil {
+				return "", err
+			}
+		} else if dependency.Version != "" && strings.HasPrefix(revision, dependency.Version) {
+			return "", nil
+		} else {
+			tags, err := vcs.Tags(root)
+			if err != nil {
+				return "", err
+			}
+			for _, tag := range tags {
+				if tag == dependency.Version {
+					return "", nil
 				}
 			}
+			if selected = vcs.Tag(root); selected == "" {
+				selected = revision
+			}
 		}
 	}
-	return p.dependencies
+
+	if _, _, ok := splitBumpable(dependency.Version); ok {
+		selected = BumpConstraint(dependency.Version, selected)
+	}
+	if selected == dependency.Version {
+		return "", nil
+	}
+	return selected, nil
 }
 
-func (p *ProjectImpl) Get(dependency Dependency) error {
-	return p.GoRun("get", dependency.Name)
+func (p *ProjectImpl) SetDependencyVersion(name string, version string) error {
+	if err := SetDependencyVersion(p.Cwd, name, version); err != nil {
+		return err
+	}
+	for i, dep := range p.dependencies {
+		if dep.Name == name {
+			p.dependencies[i].Version = version
+		}
+	}
+	return nil
+}
+
+func (p *ProjectImpl) Outdated(dependency Dependency) (*Outdated, error) {
+	if err := p.Bootstrap(); err != nil {
+		return nil, err
+	}
+	return CheckOutdated(p.VendorDir(), dependency, p.Cache.Offline)
+}
+
+func (p *ProjectImpl) Graph() (*ImportGraph, error) {
+	if err := p.Bootstrap(); err != nil {
+		return nil, err
+	}
+	return NewImportGraph(p.Name(), filepath.Join(p.Gopath()[0], "src"), p.VendorDir())
+}
+
+/**
+ * Import graph counting files of every platform and build tag and imports
+ * of project tests, what declared dependencies must cover
+ */
+func (p *ProjectImpl) FullGraph() (*ImportGraph, error) {
+	if err := p.Bootstrap(); err != nil {
+		return nil, err
+	}
+	return NewFullImportGraph(p.Name(), filepath.Join(p.Gopath()[0], "src"), p.VendorDir())
+}
+
+func (p *ProjectImpl) CheckDependencies(graph *ImportGraph) *DepsReport {
+	return CheckDependencies(graph, p.Dependencies(), p.VendorDir())
+}
+
+func (p *ProjectImpl) AddDependency(dependency Dependency) error {
+	if err := AddDependency(p.Cwd, dependency); err != nil {
+		return err
+	}
+	p.dependencies = append(p.Dependencies(), dependency)
+	return nil
+}
+
+/**
+ * Remove dependency from manifest and its repository from vendor dir,
+ * unless other dependency still lives in the same repository
+ */
+func (p *ProjectImpl) RemoveDependency(name string) error {
+	if err := RemoveDependency(p.Cwd, name); err != nil {
+		return err
+	}
+
+	remaining := []Dependency{}
+	for _, dep := range p.Dependencies() {
+		if dep.Name != name {
+			remaining = append(remaining, dep)
+		}
+	}
+	p.dependencies = remaining
+
+	root, _, err := FindRepoRoot(p.VendorDir(), filepath.Join(p.VendorDir(), name))
+	if err != nil {
+		return os.RemoveAll(filepath.Join(p.VendorDir(), name))
+	}
+
+	for _, dep := range remaining {
+		if dir := filepath.Join(p.VendorDir(), dep.Name); dir == root || strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return os.RemoveAll(root)
+}
+
+/**
+ * Clone repository from explicit remote into vendor dir, then fetch what
+ * package name inside it imports
+ */
+func (p *ProjectImpl) clone(repo string, vcs *Vcs, remote string, name string) error {
+	if vcs == nil {
+		return fmt.Errorf("%s: unknown vcs", repo)
+	}
+
+	dir := filepath.Join(p.VendorDir(), filepath.FromSlash(repo))
+	unlock := p.repos.Lock(repo)
+	if VcsForDir(dir) == nil {
+		if err := p.Cache.Create(repo, vcs, remote, dir); err != nil {
+			unlock()
+			return err
+		}
+	}
+	unlock()
+
+	return p.fetchImports(name)
+}
+
+/**
+ * Fetch package into vendor dir from the remote a user rewrite rule routes
+ * it to, from module proxy when configured, from the repository its
+ * go-import meta tag names otherwise. Cache mirroring the same remote is
+ * cloned instead, offline any cached mirror is. Imports of fetched package
+ * are fetched the same way, so rewrite rules apply to them too
+ */
+func (p *ProjectImpl) fetch(name string) error {
+	if repo, vcs, remote, ok := p.Config.Rewrite(name); ok {
+		return p.clone(repo, vcs, remote, name)
+	}
+
+	if proxy := p.proxy(name); proxy != nil {
+		return p.fetchModule(proxy, name)
+	}
+
+	if p.Cache.Offline {
+		// remote can not be discovered, cached mirror is the best guess
+		if repo, ok := p.Cache.Lookup(name); ok {
+			dest := filepath.Join(p.VendorDir(), filepath.FromSlash(repo))
+			unlock := p.repos.Lock(repo)
+			if _, err := os.Stat(dest); os.IsNotExist(err) {
+				if err = p.Cache.Clone(repo, dest); err != nil {
+					unlock()
+					return err
+				}
+			}
+			unlock()
+
+			return p.fetchImports(name)
+		}
+		if _, _, err := FindRepoRoot(p.VendorDir(), filepath.Join(p.VendorDir(), name)); err == nil {
+			return p.fetchImports(name)
+		}
+		return p.Cache.missError(name)
+	}
+
+	// go get needs module mode on current go, so find repository and
+	// clone it ourselves, from cache when mirror of the same remote
+	repo, vcs, remote, err := DiscoverRepo(name)
+	if err != nil {
+		return err
+	}
+	return p.clone(repo, vcs, remote, name)
+}
+
+// proxy to fetch package from, nil when none configured or a rewrite rule
+// routes the package to a repository
+func (p *ProjectImpl) proxy(name string) *Proxy {
+	url := p.Config.ProxyURL()
+	if url == "" {
+		return nil
+	}
+	if _, _, _, ok := p.Config.Rewrite(name); ok {
+		return nil
+	}
+	return NewProxy(url)
+}
+
+// fetch newest version of module providing package unless some version is
+// vendored already
+func (p *ProjectImpl) fetchModule(proxy *Proxy, name string) error {
+	if _, current := FindProxyModule(p.VendorDir(), name); current != nil {
+		return p.fetchImports(name)
+	}
+
+	if p.Cache.Offline {
+		return p.Cache.missError(name)
+	}
+
+	module, versions, err := proxy.FindModule(name)
+	if err != nil {
+		return err
+	}
+
+	root := filepath.Join(p.VendorDir(), filepath.FromSlash(module))
+	unlock := p.repos.Lock(module)
+	if ReadProxyModule(root) == nil {
+		var version string
+		if version, err = proxy.Resolve(module, versions, ""); err == nil {
+			err = proxy.Download(module, version, root)
+		}
+	}
+	unlock()
+
+	if err != nil {
+		return err
+	}
+	return p.fetchImports(name)
+}
+
+// fetch missing imports of vendored package the way package itself was
+func (p *ProjectImpl) fetchImports(name string) error {
+	pkg, err := build.Default.ImportDir(filepath.Join(p.VendorDir(), filepath.FromSlash(name)), 0)
+	if err != nil {
+		return nil
+	}
+
+	for _, imp := range pkg.Imports {
+		if isStandardImport(imp) ||
+			isDir(filepath.Join(p.VendorDir(), filepath.FromSlash(imp))) ||
+			isDir(filepath.Join(p.Gopath()[0], "src", filepath.FromSlash(imp))) {
+			continue
+		}
+
+		if err = p.fetch(imp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * Link dependency from local dir into project gopath instead of fetching,
+ * so it shadows any vendored copy. Copy when linking is not possible
+ */
+func (p *ProjectImpl) Link(dependency Dependency) error {
+	var (
+		source string
+		err    error
+	)
+
+	if source = dependency.Path; !filepath.IsAbs(source) {
+		source = filepath.Join(p.Cwd, source)
+	}
+	if _, err = os.Stat(source); err != nil {
+		return fmt.Errorf("%s: %s", dependency.Name, err.Error())
+	}
+
+	dest := filepath.Join(p.Gopath()[0], "src", filepath.FromSlash(dependency.Name))
+	if err = os.RemoveAll(dest); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	if err = os.Symlink(source, dest); err != nil {
+		return copy_folder(source, dest)
+	}
+	return nil
+}
+
+func (p *ProjectImpl) getLocal(dependency Dependency, chain []string) error {
+	if !p.resolve(dependency.Name, dependency.Path) {
+		return nil
+	}
+
+	manifest, err := ReadManifest(filepath.Join(p.Gopath()[0], "src", filepath.FromSlash(dependency.Name)))
+	if err != nil {
+		return err
+	}
+
+	for _, dep := range manifest.Dependencies {
+		if err = p.get(dep, chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ProjectImpl) ImportManifest(source string) (*Manifest, string, error) {
+	return ImportManifest(p.Cwd, source)
+}
+
+func (p *ProjectImpl) WriteManifest(manifest *Manifest, overwrite bool) error {
+	if _, err := os.Stat(filepath.Join(p.Cwd, GOPASYML)); err == nil && !overwrite {
+		return errors.New(GOPASYML + " already exists")
+	}
+	return WriteManifest(p.Cwd, manifest)
+}
+
+/**
+ * Write go.mod requiring what gopas.lock records, and go.sum when hashes
+ * recorded in gopas.sum are the ones go uses
+ */
+func (p *ProjectImpl) ExportGoMod(overwrite bool) error {
+	if err := p.Bootstrap(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(p.Cwd, GOMODFILE)); err == nil && !overwrite {
+		return errors.New(GOMODFILE + " already exists")
+	}
+
+	lock, err := p.Lock()
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return errors.New(LOCKFILE + " not found, run gopas install first")
+	}
+
+	mod, err := NewGoMod(p.VendorDir(), p.Name(), p.Dependencies(), lock)
+	if err != nil {
+		return err
+	}
+	if mod.Go, err = p.goVersion(); err != nil {
+		return err
+	}
+	if err = mod.Write(p.Cwd); err != nil {
+		return err
+	}
+
+	sum, err := ReadSum(p.Cwd)
+	if err != nil {
+		return err
+	}
+	lines, err := GoSumLines(p.VendorDir(), lock, sum)
+	if err != nil || len(lines) == 0 {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(p.Cwd, GOSUMFILE), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// version of go toolchain project is built with, for go directive of
+// go.mod
+func (p *ProjectImpl) goVersion() (string, error) {
+	runner := &Runner{
+		Name: p.exeGo,
+		Args: []string{"version"},
+	}
+	out, err := runner.Output()
+	if err != nil {
+		return "", err
+	}
+
+	// go version go1.21.3 linux/amd64
+	if fields := strings.Fields(out); len(fields) > 2 {
+		return GoDirectiveVersion(fields[2]), nil
+	}
+	return "", nil
+}
+
+func (p *ProjectImpl) Lock() (*Lock, error) {
+	return ReadLock(p.Cwd)
+}
+
+func (p *ProjectImpl) Scan() (*Lock, error) {
+	if err := p.Bootstrap(); err != nil {
+		return nil, err
+	}
+	return NewLock(p.VendorDir(), p.Dependencies())
+}
+
+func (p *ProjectImpl) Restore(lock *Lock) error {
+	if err := p.Bootstrap(); err != nil {
+		return err
+	}
+	return lock.Restore(p.VendorDir(), p.Cache)
+}
+
+/**
+ * Write gopas.lock and gopas.sum for what is vendored now, refusing when
+ * vendored files of an already recorded revision were modified
+ */
+func (p *ProjectImpl) WriteLock() error {
+	lock, sum, err := p.verify()
+	if err != nil {
+		return err
+	}
+
+	if err = lock.Write(p.Cwd); err != nil {
+		return err
+	}
+	return sum.Write(p.Cwd)
+}
+
+/**
+ * Check vendored packages against hashes recorded in gopas.sum
+ */
+func (p *ProjectImpl) Verify() error {
+	_, _, err := p.verify()
+	return err
+}
+
+func (p *ProjectImpl) verify() (*Lock, *Sum, error) {
+	lock, err := p.Scan()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sum, err := NewSum(p.VendorDir(), lock)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recorded, err := ReadSum(p.Cwd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if recorded != nil {
+		if err = recorded.Verify(sum); err != nil {
+			return nil, nil, err
+		}
+	}
+	return lock, sum, nil
+}
+
+/**
+ * Find license of every vendored package, modules are not vendored in
+ * module mode so their licenses are not known
+ */
+func (p *ProjectImpl) Licenses() ([]*License, error) {
+	if p.IsModule() {
+		return nil, errors.New("Licenses are scanned in _vendor, not available for " + GOMODFILE + " project")
+	}
+
+	lock, err := p.Scan()
+	if err != nil {
+		return nil, err
+	}
+	return ScanLicenses(p.VendorDir(), lock)
+}
+
+func (p *ProjectImpl) LicensePolicy() LicensePolicy {
+	p.Bootstrap()
+	return p.licenses
+}
+
+/**
+ * Match vendored packages against advisory database at path, or the one
+ * configured by user when path is empty
+ */
+func (p *ProjectImpl) Audit(advisories string) ([]*AuditFinding, []string, error) {
+	if p.IsModule() {
+		return nil, nil, errors.New("Audit checks _vendor, not available for " + GOMODFILE + " project")
+	}
+
+	if advisories == "" {
+		advisories = p.Config.AdvisoriesPath()
+	}
+	if advisories == "" {
+		return nil, nil, errors.New("Advisory database is undefined, use --db or advisories in " + USERCONFIG)
+	}
+
+	db, err := LoadAdvisories(advisories)
+	if err != nil {
+		return nil, nil, err
+	}
+	lock, err := p.Scan()
+	if err != nil {
+		return nil, nil, err
+	}
+	return db.Audit(p.VendorDir(), lock)
+}
+
+/**
+ * Remove files of vendored packages project build and tests do not need,
+ * after verifying them so gopas.sum keeps their original hashes
+ */
+func (p *ProjectImpl) Prune(dryRun bool) ([]*PrunedPackage, error) {
+	if p.IsModule() {
+		return nil, errors.New("Prune removes files in _vendor, not available for " + GOMODFILE + " project")
+	}
+
+	_, sum, err := p.verify()
+	if err != nil {
+		return nil, err
+	}
+	graph, err := p.FullGraph()
+	if err != nil {
+		return nil, err
+	}
+	return Prune(p.VendorDir(), graph, sum, dryRun)
+}
+
+/**
+ * Paths of project neither copied into gopath nor watched, from ignore of
+ * gopas.yml and .gopasignore
+ */
+func (p *ProjectImpl) Ignore() (*Ignore, error) {
+	if err := p.Bootstrap(); err != nil {
+		return nil, err
+	}
+	return ReadIgnore(p.Cwd, p.ignores)
+}
+
+func (p *ProjectImpl) Index() error {
+	_, err := p.index()
+	return err
+}
+
+func (p *ProjectImpl) Search(query string) ([]*SearchResult, error) {
+	index, err := p.index()
+	if err != nil {
+		return nil, err
+	}
+	return index.Search(query), nil
+}
+
+// add vendored packages of project to search index shared through cache
+// dir, only repositories at revision other than indexed are parsed
+func (p *ProjectImpl) index() (*SearchIndex, error) {
+	if err := p.Bootstrap(); err != nil {
+		return nil, err
+	}
+
+	lock, err := p.Lock()
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		if lock, err = p.Scan(); err != nil {
+			return nil, err
+		}
+	}
+
+	index, err := ReadSearchIndex(p.Cache.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = index.AddLocked(p.VendorDir(), lock); err != nil {
+		return nil, err
+	}
+	return index, index.Write(p.Cache.Dir)
 }
 
 func (p *ProjectImpl) PreBuild() error {
@@ -137,11 +1022,37 @@ func (p *ProjectImpl) GoRun(args ...string) error {
 	if err := p.Bootstrap(); err != nil {
 		return err
 	}
+	return p.goRun(p.Dir(), p.Env(), args...)
+}
+
+/**
+ * Run go command with vendor dir as the only gopath, so fetched
+ * dependencies land in vendor dir instead of project gopath. Stderr is
+ * captured into returned error so parallel runs do not interleave output
+ */
+func (p *ProjectImpl) vendorRun(args ...string) error {
+	if err := p.Bootstrap(); err != nil {
+		return err
+	}
+
 	runner := &Runner{
 		Name: p.exeGo,
 		Args: args,
-		Dir:  p.Dir(),
-		Env:  p.Env(),
+		Dir:  p.Gopath()[1],
+		Env:  []string{"GO111MODULE=off", "GOPATH=" + p.Gopath()[1]},
+	}
+	if _, err := runner.Output(); err != nil {
+		return fmt.Errorf("go %s: %s", strings.Join(args, " "), err.Error())
+	}
+	return nil
+}
+
+func (p *ProjectImpl) goRun(dir string, env []string, args ...string) error {
+	runner := &Runner{
+		Name: p.exeGo,
+		Args: args,
+		Dir:  dir,
+		Env:  env,
 	}
 
 	if err := runner.Run(); err != nil {
@@ -208,8 +1119,9 @@ func (p *ProjectImpl) Clean() error {
 
 func (p *ProjectImpl) Bootstrap() error {
 	var (
-		err     error
-		content []byte
+		err      error
+		manifest *Manifest
+		mod      *GoMod
 	)
 
 	if p.exeGo != "" {
@@ -219,48 +1131,120 @@ func (p *ProjectImpl) Bootstrap() error {
 		panic("Please install go")
 	}
 
-	srcDir := filepath.Join(p.Gopath()[0], "src")
-	if _, err = os.Stat(srcDir); os.IsNotExist(err) {
-		if os.MkdirAll(srcDir, 0755) != nil {
+	if p.Config == nil {
+		if p.Config, err = ReadConfig(UserConfigFile()); err != nil {
 			return err
 		}
 	}
+	if p.Cache == nil {
+		p.Cache = NewCache("", false)
+	}
 
-	if content, err = ioutil.ReadFile(filepath.Join(p.Cwd, "gopas.yml")); err == nil {
-		config := struct {
-			Name         string
-			PreBuild     [][]string `yaml:"pre-build"`
-			Dependencies []string
-		}{}
-		if err = yaml.Unmarshal(content, &config); err == nil {
-			p.name = config.Name
-			p.preBuild = config.PreBuild
-			for _, dep := range config.Dependencies {
-				depSplitted := strings.Split(dep, "=")
-				name := depSplitted[0]
-				version := ""
-				if len(depSplitted) > 1 {
-					version = depSplitted[1]
-				}
-				p.dependencies = append(p.dependencies, Dependency{
-					Name:    name,
-					Version: version,
-				})
+	if manifest, err = ReadManifest(p.Cwd); err != nil {
+		return err
+	}
+	p.name = manifest.Name
+	p.preBuild = manifest.PreBuild
+	p.dependencies = manifest.Dependencies
+	p.licenses = manifest.Licenses
+	p.ignores = manifest.Ignore
+	p.layout = manifest.Layout
+
+	// go.mod takes over dependencies, nothing to copy into gopath
+	if mod, err = ReadGoMod(p.Cwd); err != nil {
+		return err
+	} else if mod != nil {
+		p.module = true
+		if p.name == "" {
+			p.name = mod.Module
+		}
+		return nil
+	}
+
+	for _, srcDir := range []string{filepath.Join(p.Gopath()[0], "src"), p.VendorDir()} {
+		if _, err = os.Stat(srcDir); os.IsNotExist(err) {
+			if err = os.MkdirAll(srcDir, 0755); err != nil {
+				return err
 			}
 		}
 	}
 
-	if err = os.RemoveAll(p.Dir()); err != nil {
+	if err = p.place(); err != nil {
 		return err
 	}
 
-	if err = copy_folder(p.Cwd, p.Dir()); err != nil {
-		return err
+	for _, dep := range p.dependencies {
+		if dep.Path == "" {
+			continue
+		}
+		if err = p.Link(dep); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// put project into gopath, as link to cwd when layout asks for it and go
+// resolves packages through the link, as synced copy otherwise
+func (p *ProjectImpl) place() error {
+	dir := p.Dir()
+
+	switch p.layout {
+	case "", LAYOUTCOPY:
+	case LAYOUTSYMLINK:
+		if target, err := os.Readlink(dir); err == nil && target == p.Cwd {
+			return nil
+		}
+		err := p.symlink(dir)
+		if err == nil {
+			return nil
+		}
+		p.LogE("Symlink layout not usable, copying project: %s", err.Error())
+	default:
+		return fmt.Errorf("%s: unknown layout %s, expected %s or %s", GOPASYML, p.layout, LAYOUTCOPY, LAYOUTSYMLINK)
+	}
+
+	// never sync through a link, removing stale files would hit cwd
+	if fi, err := os.Lstat(dir); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err = os.Remove(dir); err != nil {
+			return err
+		}
+	}
+	ignore, err := ReadIgnore(p.Cwd, p.ignores)
+	if err != nil {
+		return err
+	}
+	return SyncDir(p.Cwd, dir, ignore)
+}
+
+func (p *ProjectImpl) symlink(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	if err := os.Symlink(p.Cwd, dir); err != nil {
+		return err
+	}
+
+	runner := &Runner{
+		Name: p.exeGo,
+		Args: []string{"list", "-e", "-f", "{{.ImportPath}}", "."},
+		Dir:  dir,
+		Env:  p.Env(),
+	}
+	out, err := runner.Output()
+	if out = strings.TrimSpace(out); err == nil && out != p.Name() {
+		err = fmt.Errorf("go resolves %s as %s", p.Name(), out)
+	}
+	if err != nil {
+		os.Remove(dir)
+	}
+	return err
+}
+
 /**
  * New project options and gopath dir
  */
@@ -277,6 +1261,7 @@ func NewProject(logger *Logger, cwd string) *ProjectImpl {
 
 	return &ProjectImpl{
 		Logger: logger,
+		Cache:  NewCache("", false),
 		Cwd:    cwd,
 	}
 }
@@ -0,0 +1,376 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	PROXYMETA = ".gopas-proxy"
+	PROXYVCS  = "proxy"
+)
+
+/**
+ * Proxy type, client of go module proxy protocol. URL may be http, https
+ * or file
+ */
+type Proxy struct {
+	URL    string
+	Client *http.Client
+}
+
+/**
+ * ProxyInfo type, version metadata served by proxy
+ */
+type ProxyInfo struct {
+	Version string
+	Time    time.Time
+}
+
+/**
+ * ProxyModule type, written into module dir it was unpacked to so lock and
+ * later installs know what is there
+ */
+type ProxyModule struct {
+	Path    string
+	Version string
+	Proxy   string
+}
+
+/**
+ * ProxyNotFoundError type, proxy does not know module or version
+ */
+type ProxyNotFoundError struct {
+	URL string
+}
+
+func (e *ProxyNotFoundError) Error() string {
+	return e.URL + " not found"
+}
+
+func NewProxy(url string) *Proxy {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+
+	return &Proxy{
+		URL:    strings.TrimSuffix(url, "/"),
+		Client: &http.Client{Transport: transport, Timeout: 5 * time.Minute},
+	}
+}
+
+/**
+ * Escape module path or version for proxy url, upper case letters become
+ * ! followed by the lower case letter
+ *
+ * @param {string} s
+ * @return {string}
+ */
+func EscapeModulePath(s string) string {
+	escaped := strings.Builder{}
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			escaped.WriteRune('!')
+			r = unicode.ToLower(r)
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+func (p *Proxy) get(path string) ([]byte, error) {
+	url := p.URL + "/" + path
+
+	resp, err := p.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, &ProxyNotFoundError{URL: url}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+/**
+ * List known versions of module
+ *
+ * @param {string} module
+ * @return {[]string}
+ * @return {error}
+ */
+func (p *Proxy) List(module string) ([]string, error) {
+	content, err := p.get(EscapeModulePath(module) + "/@v/list")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(content)), nil
+}
+
+/**
+ * Resolve query (version, branch or commit) into canonical version
+ *
+ * @param {string} module
+ * @param {string} query
+ * @return {*ProxyInfo}
+ * @return {error}
+ */
+func (p *Proxy) Info(module string, query string) (*ProxyInfo, error) {
+	return p.info(EscapeModulePath(module) + "/@v/" + EscapeModulePath(query) + ".info")
+}
+
+/**
+ * Resolve newest version of module, pseudo version when it has no tag
+ *
+ * @param {string} module
+ * @return {*ProxyInfo}
+ * @return {error}
+ */
+func (p *Proxy) Latest(module string) (*ProxyInfo, error) {
+	return p.info(EscapeModulePath(module) + "/@latest")
+}
+
+func (p *Proxy) info(path string) (*ProxyInfo, error) {
+	content, err := p.get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ProxyInfo{}
+	if err = json.Unmarshal(content, info); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return info, nil
+}
+
+/**
+ * Find module providing package, longest path proxy knows wins
+ *
+ * @param {string} importPath
+ * @return {string}
+ * @return {[]string}
+ * @return {error}
+ */
+func (p *Proxy) FindModule(importPath string) (string, []string, error) {
+	for module := importPath; strings.Contains(module, "/"); module = module[:strings.LastIndex(module, "/")] {
+		versions, err := p.List(module)
+		if _, ok := err.(*ProxyNotFoundError); ok {
+			continue
+		} else if err != nil {
+			return "", nil, err
+		}
+
+		// untagged module lists nothing but still has latest
+		if len(versions) > 0 {
+			return module, versions, nil
+		}
+		if _, err = p.Latest(module); err == nil {
+			return module, versions, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no module provides %s in %s", importPath, p.URL)
+}
+
+/**
+ * Resolve version of module to download, highest listed release when
+ * version is empty, canonical version of tag, branch or commit otherwise
+ *
+ * @param {string} module
+ * @param {[]string} versions
+ * @param {string} version
+ * @return {string}
+ * @return {error}
+ */
+func (p *Proxy) Resolve(module string, versions []string, version string) (string, error) {
+	if version == "" {
+		if latest := LatestVersion(versions); latest != "" {
+			return latest, nil
+		}
+		info, err := p.Latest(module)
+		if err != nil {
+			return "", err
+		}
+		return info.Version, nil
+	}
+
+	for _, v := range versions {
+		if v == version {
+			return v, nil
+		}
+	}
+
+	info, err := p.Info(module, version)
+	if err != nil {
+		return "", err
+	}
+	return info.Version, nil
+}
+
+/**
+ * Download zip of module version and unpack it into dir, replacing files
+ * of module that was there. Nested modules and repositories vendored
+ * inside dir are left alone
+ *
+ * @param {string} module
+ * @param {string} version
+ * @param {string} dir
+ * @return {error}
+ */
+func (p *Proxy) Download(module string, version string, dir string) error {
+	content, err := p.get(EscapeModulePath(module) + "/@v/" + EscapeModulePath(version) + ".zip")
+	if err != nil {
+		return err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return fmt.Errorf("%s@%s: %s", module, version, err.Error())
+	}
+
+	if err = removeModuleFiles(dir); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	prefix := module + "@" + version + "/"
+	for _, file := range archive.File {
+		if !strings.HasPrefix(file.Name, prefix) {
+			return fmt.Errorf("%s@%s: unexpected file %s", module, version, file.Name)
+		}
+
+		name := filepath.FromSlash(strings.TrimPrefix(file.Name, prefix))
+		if name == "" || strings.HasSuffix(file.Name, "/") {
+			continue
+		}
+		if filepath.IsAbs(name) || strings.HasPrefix(filepath.Clean(name), "..") {
+			return fmt.Errorf("%s@%s: file %s outside module", module, version, file.Name)
+		}
+
+		if err = unzipFile(file, filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	return writeProxyModule(dir, &ProxyModule{
+		Path:    module,
+		Version: version,
+		Proxy:   p.URL,
+	})
+}
+
+// remove what module unpacked into dir left, skipping nested modules and
+// repositories below it, then dirs that became empty
+func removeModuleFiles(dir string) error {
+	if _, err := os.Lstat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	dirs := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return os.Remove(path)
+		}
+		if path != dir && (ReadProxyModule(path) != nil || VcsForDir(path) != nil) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// deepest first, removing dir fails while it still holds nested module
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+	return nil
+}
+
+/**
+ * Find module downloaded from proxy containing package inside vendor dir
+ *
+ * @param {string} vendorDir
+ * @param {string} name
+ * @return {string}
+ * @return {*ProxyModule}
+ */
+func FindProxyModule(vendorDir string, name string) (string, *ProxyModule) {
+	for dir := filepath.Join(vendorDir, filepath.FromSlash(name)); strings.HasPrefix(dir, vendorDir+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if module := ReadProxyModule(dir); module != nil {
+			return dir, module
+		}
+	}
+	return "", nil
+}
+
+func unzipFile(file *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	r, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+/**
+ * Read what module version was unpacked into dir, nil when dir was not
+ * downloaded from proxy
+ *
+ * @param {string} dir
+ * @return {*ProxyModule}
+ */
+func ReadProxyModule(dir string) *ProxyModule {
+	content, err := ioutil.ReadFile(filepath.Join(dir, PROXYMETA))
+	if err != nil {
+		return nil
+	}
+
+	module := &ProxyModule{}
+	if err = yaml.Unmarshal(content, module); err != nil || module.Version == "" {
+		return nil
+	}
+	return module
+}
+
+func writeProxyModule(dir string, module *ProxyModule) error {
+	content, err := yaml.Marshal(module)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, PROXYMETA), content, 0644)
+}
@@ -0,0 +1,235 @@
+package util
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	PRUNEMETA = ".gopas-pruned"
+)
+
+/**
+ * Prune types, marker left at root of pruned package and what was removed
+ * from each package
+ */
+type (
+	PruneMarker struct {
+		Revision string
+		Hash     string
+		Pruned   string
+	}
+
+	PrunedPackage struct {
+		Name  string
+		Files []string
+		Bytes int64
+	}
+)
+
+// files go build may need next to go files of a package
+var buildExtensions = map[string]bool{
+	".go": true, ".s": true, ".S": true, ".sx": true, ".c": true, ".h": true,
+	".cc": true, ".cpp": true, ".cxx": true, ".hh": true, ".hpp": true, ".hxx": true,
+	".m": true, ".f": true, ".F": true, ".for": true, ".f90": true,
+	".syso": true, ".swig": true, ".swigcxx": true,
+}
+
+// files kept anywhere, legal notices and manifests of package
+var keptPrefixes = []string{"license", "licence", "copying", "unlicense", "notice", "authors", "contributors", "patents"}
+
+/**
+ * Read prune marker at root of package dir, nil when not pruned
+ *
+ * @param {string} dir
+ * @return {*PruneMarker}
+ */
+func ReadPruneMarker(dir string) *PruneMarker {
+	content, err := ioutil.ReadFile(filepath.Join(dir, PRUNEMETA))
+	if err != nil {
+		return nil
+	}
+
+	marker := &PruneMarker{}
+	if err = yaml.Unmarshal(content, marker); err != nil || marker.Revision == "" {
+		return nil
+	}
+	return marker
+}
+
+/**
+ * Remove files of every package in sum that building the project does not
+ * need: tests, examples and other packages the import graph never reaches,
+ * and files other than go, assembly, cgo sources and embedded ones. Legal
+ * notices and manifests are kept. Each pruned package remembers its hash
+ * before pruning, so gopas.sum stays the same. Nothing is removed with
+ * dry run
+ *
+ * @param {string} vendorDir
+ * @param {*ImportGraph} graph
+ * @param {*Sum} sum
+ * @param {bool} dryRun
+ * @return {[]*PrunedPackage}
+ * @return {error}
+ */
+func Prune(vendorDir string, graph *ImportGraph, sum *Sum, dryRun bool) ([]*PrunedPackage, error) {
+	packages, embedded := map[string]bool{}, map[string]bool{}
+	for _, pkg := range graph.Reachable() {
+		dir := graph.Dirs[pkg]
+		if dir == "" {
+			continue
+		}
+		packages[filepath.Clean(dir)] = true
+
+		files, err := embeddedFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			embedded[filepath.Clean(file)] = true
+		}
+	}
+
+	roots := map[string]bool{}
+	for _, entry := range sum.Entries {
+		roots[filepath.Join(vendorDir, filepath.FromSlash(entry.Name))] = true
+	}
+
+	pruned := []*PrunedPackage{}
+	for _, entry := range sum.Entries {
+		root := filepath.Join(vendorDir, filepath.FromSlash(entry.Name))
+		pkg := &PrunedPackage{Name: entry.Name, Files: []string{}}
+
+		err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				if path != root && (isVcsMeta(fi.Name()) || roots[path]) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if isPruneKept(path, packages, embedded) {
+				return nil
+			}
+			rel, err := filepath.Rel(vendorDir, path)
+			if err != nil {
+				return err
+			}
+			pkg.Files = append(pkg.Files, filepath.ToSlash(rel))
+			pkg.Bytes += fi.Size()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pkg.Files) == 0 {
+			continue
+		}
+		pruned = append(pruned, pkg)
+
+		if dryRun {
+			continue
+		}
+		if err = removePruned(vendorDir, root, pkg.Files); err != nil {
+			return nil, err
+		}
+
+		marker := &PruneMarker{Revision: entry.Revision, Hash: entry.Hash}
+		if marker.Pruned, err = HashDir(root, entry.Name+"@"+entry.Revision); err != nil {
+			return nil, err
+		}
+		content, err := yaml.Marshal(marker)
+		if err != nil {
+			return nil, err
+		}
+		if err = ioutil.WriteFile(filepath.Join(root, PRUNEMETA), content, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return pruned, nil
+}
+
+func isPruneKept(path string, packages map[string]bool, embedded map[string]bool) bool {
+	name := filepath.Base(path)
+	lower := strings.ToLower(name)
+	for _, prefix := range keptPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+
+	switch {
+	case name == GOMODFILE || name == GOSUMFILE || name == GOPASYML || name == GOPASFILE:
+		return true
+	case name == PROXYMETA || name == PRUNEMETA:
+		return true
+	case strings.HasSuffix(name, "_test.go"):
+		return false
+	case filepath.Ext(name) == ".h":
+		// cgo may include headers of other dirs
+		return true
+	case packages[filepath.Dir(path)] && buildExtensions[filepath.Ext(name)]:
+		return true
+	}
+
+	for dir := path; dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if embedded[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// files and dirs matched by go:embed patterns of package at dir
+func embeddedFiles(dir string) ([]string, error) {
+	context := build.Default
+	context.UseAllFiles = true
+	pkg, err := context.ImportDir(dir, 0)
+	if pkg == nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, pattern := range pkg.EmbedPatterns {
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(pattern, "all:"))))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// remove files relative to vendor dir, then dirs left empty inside root
+func removePruned(vendorDir string, root string, files []string) error {
+	dirs := map[string]bool{}
+	for _, file := range files {
+		path := filepath.Join(vendorDir, filepath.FromSlash(file))
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	// deepest first, so parents are empty by the time they are reached
+	sorted := sortedKeys(dirs)
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+	for _, dir := range sorted {
+		if infos, err := ioutil.ReadDir(dir); err == nil && len(infos) == 0 {
+			os.Remove(dir)
+		}
+	}
+	return nil
+}
@@ -1,11 +1,13 @@
 package util
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
@@ -22,6 +24,12 @@ type Runner struct {
 	Out     io.Writer
 	Err     io.Writer
 	command *exec.Cmd
+	exit    *runnerExit
+}
+
+type runnerExit struct {
+	done chan struct{}
+	err  error
 }
 
 /**
@@ -33,8 +41,6 @@ type Runner struct {
 func (r *Runner) Run() error {
 	if r.command == nil || r.IsExited() {
 		var (
-			stdout io.ReadCloser
-			stderr io.ReadCloser
 			//stdin  io.WriteCloser
 			err error
 		)
@@ -56,13 +62,9 @@ func (r *Runner) Run() error {
 		r.command.Env = r.GetEnv()
 		r.command.Dir = r.GetDir()
 
-		if stdout, err = r.command.StdoutPipe(); err != nil {
-			return err
-		}
-
-		if stderr, err = r.command.StderrPipe(); err != nil {
-			return err
-		}
+		// let exec copy output, so Wait returns only after all output written
+		r.command.Stdout = r.Out
+		r.command.Stderr = r.Err
 
 		// FIXME not working attaching stdin, weird stuff happens
 		///if stdin, err = r.command.StdinPipe(); err != nil {
@@ -76,16 +78,52 @@ func (r *Runner) Run() error {
 		// FIXME not working
 		// go io.Copy(stdin, os.Stdin)
 
-		go io.Copy(r.Out, stdout)
-		go io.Copy(r.Err, stderr)
-		go r.command.Wait()
+		// wait in background once, so IsExited and Kill can observe exit
+		exit := &runnerExit{done: make(chan struct{})}
+		r.exit = exit
+		go func(command *exec.Cmd) {
+			exit.err = command.Wait()
+			close(exit.done)
+		}(r.command)
 	}
 
 	return nil
 }
 
 func (r *Runner) Wait() error {
-	return r.command.Wait()
+	if r.exit == nil {
+		return errors.New("Runner is not started")
+	}
+
+	<-r.exit.done
+	return r.exit.err
+}
+
+/**
+ * Run command until exit and collect its output
+ *
+ * @return {string}
+ * @return {error}
+ */
+func (r *Runner) Output() (string, error) {
+	var stderr bytes.Buffer
+
+	if r.Name == "" {
+		return "", errors.New("Name is undefined")
+	}
+
+	command := exec.Command(r.Name, r.Args...)
+	command.Env = r.GetEnv()
+	command.Dir = r.GetDir()
+	command.Stderr = &stderr
+
+	out, err := command.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%s: %s", err.Error(), msg)
+		}
+	}
+	return string(out), err
 }
 
 /**
@@ -94,18 +132,35 @@ func (r *Runner) Wait() error {
  * @return {bool}
  */
 func (r *Runner) IsExited() bool {
-	return r.command != nil && r.command.ProcessState != nil && r.command.ProcessState.Exited()
+	if r.command == nil || r.exit == nil {
+		return false
+	}
+
+	select {
+	case <-r.exit.done:
+		return true
+	default:
+		return false
+	}
 }
 
 func (r *Runner) GetEnv() []string {
+	vars := r.Env
+	if r.Dir != "" {
+		// go trusts PWD over getcwd, so paths through a link stay as given
+		if dir, err := filepath.Abs(r.Dir); err == nil {
+			vars = append([]string{"PWD=" + dir}, vars...)
+		}
+	}
+
 	prefixes := []string{}
-	for _, v := range r.Env {
+	for _, v := range vars {
 		splitted := strings.Split(v, "=")
 		prefixes = append(prefixes, splitted[0]+"=")
 	}
 
 	env := []string{}
-	env = append(env, r.Env...)
+	env = append(env, vars...)
 
 	for _, v := range os.Environ() {
 		use := true
@@ -138,13 +193,7 @@ func (r *Runner) GetDir() string {
  */
 func (r *Runner) Kill() error {
 	if r.command != nil && r.command.Process != nil {
-		done := make(chan error)
-		go func() {
-			if r.command != nil {
-				r.command.Wait()
-			}
-			close(done)
-		}()
+		done := r.exit.done
 
 		//log.Println("[RUNNER] soft killing ...", os.Args)
 		//Trying a "soft" kill first
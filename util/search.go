@@ -0,0 +1,345 @@
+package util
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	SEARCHINDEX = "index.json"
+)
+
+/**
+ * SearchIndex type, every package seen in vendor dirs of any project keyed
+ * by import path
+ */
+type SearchIndex struct {
+	Packages  map[string]*IndexedPackage `json:"packages"`
+	Revisions map[string]string          `json:"revisions"`
+}
+
+/**
+ * IndexedPackage type, what search matches query against
+ */
+type IndexedPackage struct {
+	Path     string   `json:"path"`
+	Name     string   `json:"name"`
+	Synopsis string   `json:"synopsis"`
+	Exported []string `json:"exported"`
+}
+
+/**
+ * SearchResult type, matching package with its score and the exported
+ * identifiers matching query
+ */
+type SearchResult struct {
+	*IndexedPackage
+	Score   int
+	Matches []string
+}
+
+/**
+ * Read search index from dir, empty index when it does not exist yet
+ *
+ * @param {string} dir
+ * @return {*SearchIndex}
+ * @return {error}
+ */
+func ReadSearchIndex(dir string) (*SearchIndex, error) {
+	index := &SearchIndex{Packages: map[string]*IndexedPackage{}, Revisions: map[string]string{}}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, SEARCHINDEX))
+	if os.IsNotExist(err) {
+		return index, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(content, index); err != nil {
+		return nil, err
+	}
+	if index.Packages == nil {
+		index.Packages = map[string]*IndexedPackage{}
+	}
+	if index.Revisions == nil {
+		index.Revisions = map[string]string{}
+	}
+	return index, nil
+}
+
+/**
+ * Write search index into dir, through temporary file so concurrent
+ * readers never see half written index
+ *
+ * @param {string} dir
+ * @return {error}
+ */
+func (i *SearchIndex) Write(dir string) error {
+	content, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, SEARCHINDEX)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, SEARCHINDEX))
+}
+
+/**
+ * Index every package inside src dir, replacing what index knew about them
+ *
+ * @param {string} srcDir
+ * @return {error}
+ */
+func (i *SearchIndex) AddDir(srcDir string) error {
+	err := filepath.Walk(srcDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() || path == srcDir {
+			return err
+		}
+
+		if isIgnoredPackageDir(fi.Name()) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+
+		if pkg := parsePackage(filepath.ToSlash(rel), path); pkg != nil {
+			i.Packages[pkg.Path] = pkg
+		}
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+/**
+ * Index packages of every locked package inside vendor dir at revision
+ * index has not seen it at, so only repositories and modules that changed
+ * are parsed again. What index knew about their packages is replaced
+ *
+ * @param {string} vendorDir
+ * @param {*Lock} lock
+ * @return {error}
+ */
+func (i *SearchIndex) AddLocked(vendorDir string, lock *Lock) error {
+	roots := map[string]bool{}
+	for _, pkg := range lock.Packages {
+		roots[filepath.Join(vendorDir, filepath.FromSlash(pkg.Name))] = true
+	}
+
+	for _, locked := range lock.Packages {
+		if i.Revisions[locked.Name] == locked.Revision {
+			continue
+		}
+
+		// packages of nested repository or module belong to its own entry
+		for path := range i.Packages {
+			if (path == locked.Name || strings.HasPrefix(path, locked.Name+"/")) && lock.Package(path).Name == locked.Name {
+				delete(i.Packages, path)
+			}
+		}
+
+		root := filepath.Join(vendorDir, filepath.FromSlash(locked.Name))
+		err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil || !fi.IsDir() {
+				return err
+			}
+			if path != root && (isIgnoredPackageDir(fi.Name()) || roots[path]) {
+				return filepath.SkipDir
+			}
+
+			rel, err := filepath.Rel(vendorDir, path)
+			if err != nil {
+				return err
+			}
+			if pkg := parsePackage(filepath.ToSlash(rel), path); pkg != nil {
+				i.Packages[pkg.Path] = pkg
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		i.Revisions[locked.Name] = locked.Revision
+	}
+	return nil
+}
+
+// parse non test go files of dir, nil when dir holds no parsable package
+func parsePackage(importPath string, dir string) *IndexedPackage {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+
+	// documentation only packages may sit next to the real one, the one
+	// with most files wins
+	var astPkg *ast.Package
+	for _, p := range pkgs {
+		if astPkg == nil || len(p.Files) > len(astPkg.Files) {
+			astPkg = p
+		}
+	}
+	if astPkg == nil {
+		return nil
+	}
+
+	pkg := &IndexedPackage{
+		Path:     importPath,
+		Name:     astPkg.Name,
+		Exported: []string{},
+	}
+
+	exported := map[string]bool{}
+	for _, file := range astPkg.Files {
+		if pkg.Synopsis == "" && file.Doc != nil {
+			pkg.Synopsis = doc.Synopsis(file.Doc.Text())
+		}
+
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if !decl.Name.IsExported() {
+					continue
+				}
+				if decl.Recv == nil {
+					exported[decl.Name.Name] = true
+				} else if recv := receiverName(decl.Recv); ast.IsExported(recv) {
+					exported[recv+"."+decl.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						if spec.Name.IsExported() {
+							exported[spec.Name.Name] = true
+						}
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							if name.IsExported() {
+								exported[name.Name] = true
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	pkg.Exported = sortedKeys(exported)
+
+	return pkg
+}
+
+func receiverName(recv *ast.FieldList) string {
+	if len(recv.List) == 0 {
+		return ""
+	}
+
+	expr := recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+/**
+ * Find packages matching every word of query, case insensitive, best
+ * matches first. Package name and import path weigh more than synopsis,
+ * synopsis more than exported identifiers
+ *
+ * @param {string} query
+ * @return {[]*SearchResult}
+ */
+func (i *SearchIndex) Search(query string) []*SearchResult {
+	terms := strings.Fields(strings.ToLower(query))
+	results := []*SearchResult{}
+	if len(terms) == 0 {
+		return results
+	}
+
+	for _, pkg := range i.Packages {
+		result := &SearchResult{IndexedPackage: pkg}
+		matches := map[string]bool{}
+
+		for _, term := range terms {
+			score := 0
+			base := strings.ToLower(pkg.Path[strings.LastIndex(pkg.Path, "/")+1:])
+			switch {
+			case strings.ToLower(pkg.Name) == term || base == term:
+				score += 10
+			case strings.Contains(strings.ToLower(pkg.Path), term):
+				score += 4
+			}
+
+			if strings.Contains(strings.ToLower(pkg.Synopsis), term) {
+				score += 3
+			}
+
+			for _, ident := range pkg.Exported {
+				lower := strings.ToLower(ident)
+				if lower == term || strings.HasSuffix(lower, "."+term) {
+					score += 5
+				} else if strings.Contains(lower, term) {
+					score += 2
+				} else {
+					continue
+				}
+				matches[ident] = true
+			}
+
+			if score == 0 {
+				result.Score = 0
+				break
+			}
+			result.Score += score
+		}
+
+		if result.Score > 0 {
+			result.Matches = sortedKeys(matches)
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].Path < results[b].Path
+	})
+	return results
+}
@@ -0,0 +1,485 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Semantic version and constraint types
+ */
+type (
+	Version struct {
+		Major, Minor, Patch int
+		Pre                 string
+		Original            string
+	}
+
+	Constraint struct {
+		original string
+		sets     [][]comparator
+	}
+
+	comparator struct {
+		op      string
+		version *Version
+	}
+
+	Requirement struct {
+		Version string
+		Chain   []string
+	}
+
+	ConflictError struct {
+		Name         string
+		Requirements []Requirement
+	}
+)
+
+/**
+ * Parse version like v1.2.3, 1.2 or 1.2.3-rc.1, build metadata is ignored
+ *
+ * @param {string} s
+ * @return {*Version}
+ * @return {error}
+ */
+func ParseVersion(s string) (*Version, error) {
+	v, wildcard, err := parseVersion(s)
+	if err == nil && wildcard < 3 {
+		err = fmt.Errorf("invalid version %s", s)
+	}
+	return v, err
+}
+
+// parse version that may be partial or contains x wildcard, wildcard tells
+// index of first missing or wildcard part
+func parseVersion(s string) (*Version, int, error) {
+	v := &Version{Original: s}
+
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(str, "+"); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.Index(str, "-"); i >= 0 {
+		v.Pre = str[i+1:]
+		str = str[:i]
+	}
+
+	parts := strings.Split(str, ".")
+	if str == "" || len(parts) > 3 {
+		return nil, 0, fmt.Errorf("invalid version %s", s)
+	}
+
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	wildcard := len(parts)
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			if wildcard > i {
+				wildcard = i
+			}
+			continue
+		} else if wildcard < i {
+			return nil, 0, fmt.Errorf("invalid version %s", s)
+		}
+
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, 0, fmt.Errorf("invalid version %s", s)
+		}
+		*nums[i] = n
+	}
+
+	if v.Pre != "" && wildcard < 3 {
+		return nil, 0, fmt.Errorf("invalid version %s", s)
+	}
+	return v, wildcard, nil
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+/**
+ * Compare versions, return -1, 0 or 1
+ *
+ * @param {*Version} o
+ * @return {int}
+ */
+func (v *Version) Compare(o *Version) int {
+	for _, pair := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if pair[0] < pair[1] {
+			return -1
+		} else if pair[0] > pair[1] {
+			return 1
+		}
+	}
+
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	return comparePre(v.Pre, o.Pre)
+}
+
+func comparePre(a string, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr == nil && berr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aerr == nil:
+			return -1
+		case berr == nil:
+			return 1
+		case as[i] != bs[i]:
+			if as[i] < bs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+/**
+ * Check whether version string is a range instead of plain ref (tag,
+ * branch or commit)
+ *
+ * @param {string} s
+ * @return {bool}
+ */
+func IsConstraint(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+
+	if strings.ContainsAny(s, "^~<>=* |") {
+		return true
+	}
+
+	_, wildcard, err := parseVersion(s)
+	return err == nil && wildcard < 3 && strings.ContainsAny(s, "xX")
+}
+
+/**
+ * Parse constraint like ^1.2, ~0.4.1, >=2.0 <3 or 1.x || 2.x, space
+ * separated comparators must all match, || separated sets are alternatives
+ *
+ * @param {string} s
+ * @return {*Constraint}
+ * @return {error}
+ */
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{original: s}
+
+	for _, set := range strings.Split(s, "||") {
+		comparators := []comparator{}
+
+		fields := strings.Fields(set)
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+
+			// allow space between operator and version, e.g. ">= 1.2"
+			if strings.Trim(field, "<>=^~") == "" && i+1 < len(fields) {
+				i++
+				field += fields[i]
+			}
+
+			parsed, err := parseComparator(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %s: %s", s, err.Error())
+			}
+			comparators = append(comparators, parsed...)
+		}
+
+		if len(comparators) == 0 {
+			return nil, fmt.Errorf("invalid constraint %s", s)
+		}
+		c.sets = append(c.sets, comparators)
+	}
+
+	return c, nil
+}
+
+func parseComparator(s string) ([]comparator, error) {
+	op := s[:len(s)-len(strings.TrimLeft(s, "<>=^~"))]
+	v, wildcard, err := parseVersion(s[len(op):])
+	if err != nil {
+		return nil, err
+	}
+
+	lower := func(v *Version) comparator {
+		return comparator{">=", v}
+	}
+	upper := func(major int, minor int, patch int) comparator {
+		return comparator{"<", &Version{Major: major, Minor: minor, Patch: patch, Pre: "0"}}
+	}
+
+	// wildcard and partial versions turn into range of what is missing
+	var next comparator
+	switch wildcard {
+	case 0:
+		return []comparator{lower(&Version{})}, nil
+	case 1:
+		next = upper(v.Major+1, 0, 0)
+	case 2:
+		next = upper(v.Major, v.Minor+1, 0)
+	}
+
+	switch op {
+	case "^":
+		switch {
+		case v.Major > 0 || wildcard == 1:
+			return []comparator{lower(v), upper(v.Major+1, 0, 0)}, nil
+		case v.Minor > 0 || wildcard == 2:
+			return []comparator{lower(v), upper(0, v.Minor+1, 0)}, nil
+		}
+		return []comparator{lower(v), upper(0, 0, v.Patch+1)}, nil
+	case "~":
+		if wildcard == 1 {
+			return []comparator{lower(v), upper(v.Major+1, 0, 0)}, nil
+		}
+		return []comparator{lower(v), upper(v.Major, v.Minor+1, 0)}, nil
+	case "", "=":
+		if wildcard < 3 {
+			return []comparator{lower(v), next}, nil
+		}
+		return []comparator{{"=", v}}, nil
+	case ">":
+		if wildcard < 3 {
+			return []comparator{{">=", next.version}}, nil
+		}
+		return []comparator{{">", v}}, nil
+	case ">=":
+		return []comparator{lower(v)}, nil
+	case "<":
+		return []comparator{{"<", v}}, nil
+	case "<=":
+		if wildcard < 3 {
+			return []comparator{next}, nil
+		}
+		return []comparator{{"<=", v}}, nil
+	}
+	return nil, errors.New("unknown operator " + op)
+}
+
+func (c comparator) check(v *Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+/**
+ * Check whether version satisfies constraint, prerelease version only
+ * matches when constraint mentions prerelease of the same version
+ *
+ * @param {*Version} v
+ * @return {bool}
+ */
+func (c *Constraint) Check(v *Version) bool {
+	for _, set := range c.sets {
+		ok, pre := true, v.Pre == ""
+		for _, comp := range set {
+			if !comp.check(v) {
+				ok = false
+				break
+			}
+			cv := comp.version
+			if cv.Pre != "" && cv.Pre != "0" && cv.Major == v.Major && cv.Minor == v.Minor && cv.Patch == v.Patch {
+				pre = true
+			}
+		}
+		if ok && pre {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Constraint) String() string {
+	return c.original
+}
+
+/**
+ * Raise lower bound of constraint written as single ^, ~ or >= comparator
+ * to version, so manifest records what update selected. Other constraints
+ * and versions that are no semver are returned as is
+ *
+ * @param {string} constraint
+ * @param {string} version
+ * @return {string}
+ */
+func BumpConstraint(constraint string, version string) string {
+	op, bound, ok := splitBumpable(constraint)
+	if !ok {
+		return constraint
+	}
+	if _, err := ParseVersion(version); err != nil {
+		return constraint
+	}
+
+	if !strings.HasPrefix(bound, "v") {
+		version = strings.TrimPrefix(version, "v")
+	}
+	return op + version
+}
+
+// split constraint written as single ^, ~ or >= comparator into operator
+// and lower bound, false for every other constraint
+func splitBumpable(constraint string) (string, string, bool) {
+	s := strings.TrimSpace(constraint)
+	bound := strings.TrimLeft(s, "^~>=")
+	op := s[:len(s)-len(bound)]
+	if (op != "^" && op != "~" && op != ">=") || strings.ContainsAny(bound, " |<>=") {
+		return "", "", false
+	}
+	return op, bound, true
+}
+
+/**
+ * Select highest tag satisfying every requirement, plain refs must be
+ * required identically and satisfy ranges when they look like versions
+ *
+ * @param {string} name
+ * @param {[]string} tags
+ * @param {[]Requirement} requirements
+ * @return {string}
+ * @return {error}
+ */
+func SelectVersion(name string, tags []string, requirements []Requirement) (string, error) {
+	var (
+		ref         *Requirement
+		ranges      []Requirement
+		constraints []*Constraint
+	)
+
+	for i, req := range requirements {
+		if req.Version == "" {
+			continue
+		}
+
+		if !IsConstraint(req.Version) {
+			if ref != nil && ref.Version != req.Version {
+				return "", &ConflictError{Name: name, Requirements: []Requirement{*ref, req}}
+			}
+			ref = &requirements[i]
+			continue
+		}
+
+		constraint, err := ParseConstraint(req.Version)
+		if err != nil {
+			return "", err
+		}
+		ranges = append(ranges, req)
+		constraints = append(constraints, constraint)
+	}
+
+	if ref != nil {
+		if v, err := ParseVersion(ref.Version); err == nil {
+			for i, constraint := range constraints {
+				if !constraint.Check(v) {
+					return "", &ConflictError{Name: name, Requirements: []Requirement{*ref, ranges[i]}}
+				}
+			}
+		}
+		return ref.Version, nil
+	}
+
+	if len(constraints) == 0 {
+		return "", nil
+	}
+
+	versions := []*Version{}
+	for _, tag := range tags {
+		if v, err := ParseVersion(tag); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j]) > 0
+	})
+
+	for _, v := range versions {
+		if checkAll(constraints, v) {
+			return v.Original, nil
+		}
+	}
+
+	// find which requirements can not be satisfied together to report
+	for i := range constraints {
+		for j := 0; j <= i; j++ {
+			found := false
+			for _, v := range versions {
+				if constraints[i].Check(v) && constraints[j].Check(v) {
+					found = true
+					break
+				}
+			}
+			if !found && i == j {
+				return "", &ConflictError{Name: name, Requirements: []Requirement{ranges[i]}}
+			} else if !found {
+				return "", &ConflictError{Name: name, Requirements: []Requirement{ranges[j], ranges[i]}}
+			}
+		}
+	}
+	return "", &ConflictError{Name: name, Requirements: ranges}
+}
+
+func checkAll(constraints []*Constraint, v *Version) bool {
+	for _, constraint := range constraints {
+		if !constraint.Check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *ConflictError) Error() string {
+	if len(e.Requirements) == 1 {
+		return fmt.Sprintf("no version of %s matches %s required by %s",
+			e.Name, e.Requirements[0].Version, strings.Join(e.Requirements[0].Chain, " -> "))
+	}
+
+	lines := []string{"conflicting versions of " + e.Name + ":"}
+	for _, req := range e.Requirements {
+		lines = append(lines, fmt.Sprintf("  %s requires %s", strings.Join(req.Chain, " -> "), req.Version))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,235 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	SUMFILE = "gopas.sum"
+)
+
+/**
+ * SumEntry type, content hash of locked package at its revision
+ */
+type SumEntry struct {
+	Name     string
+	Revision string
+	Hash     string
+}
+
+/**
+ * Sum type, content hashes of every package recorded in lock, read from
+ * and written to gopas.sum
+ */
+type Sum struct {
+	Entries []SumEntry
+}
+
+/**
+ * Hash files of dir the way go dirhash Hash1 does, every file name is
+ * prefixed with prefix followed by slash. Vcs metadata is left out, so
+ * are modules and repositories nested below dir, they are hashed on their
+ * own
+ *
+ * @param {string} dir
+ * @param {string} prefix
+ * @return {string}
+ * @return {error}
+ */
+func HashDir(dir string, prefix string) (string, error) {
+	files := []string{}
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			if path != dir && (isVcsMeta(fi.Name()) || ReadProxyModule(path) != nil || VcsForDir(path) != nil) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if path == filepath.Join(dir, PROXYMETA) || path == filepath.Join(dir, PRUNEMETA) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	return hashFiles(prefix, files, func(file string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(file)))
+	})
+}
+
+/**
+ * Hash go.mod of module version the way go.sum records it
+ *
+ * @param {[]byte} content
+ * @return {string}
+ * @return {error}
+ */
+func HashGoMod(content []byte) (string, error) {
+	return hashFiles("", []string{GOMODFILE}, func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	})
+}
+
+// go dirhash Hash1 of sorted files, names prefixed unless prefix is empty
+func hashFiles(prefix string, files []string, open func(file string) (io.ReadCloser, error)) (string, error) {
+	summary := sha256.New()
+	for _, file := range files {
+		h := sha256.New()
+		f, err := open(file)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		name := file
+		if prefix != "" {
+			name = prefix + "/" + file
+		}
+		fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), name)
+	}
+
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+func isVcsMeta(name string) bool {
+	for _, vcs := range vcsList {
+		if vcs.Meta == name {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Hash every package recorded in lock inside vendor dir, nested package
+ * gets its own entry. Package pruned at its revision and untouched since
+ * has the hash it had before pruning
+ *
+ * @param {string} vendorDir
+ * @param {*Lock} lock
+ * @return {*Sum}
+ * @return {error}
+ */
+func NewSum(vendorDir string, lock *Lock) (*Sum, error) {
+	sum := &Sum{Entries: []SumEntry{}}
+
+	for _, pkg := range lock.Packages {
+		dir := filepath.Join(vendorDir, filepath.FromSlash(pkg.Name))
+		hash, err := HashDir(dir, pkg.Name+"@"+pkg.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", pkg.Name, err.Error())
+		}
+		if marker := ReadPruneMarker(dir); marker != nil && marker.Revision == pkg.Revision && marker.Pruned == hash {
+			hash = marker.Hash
+		}
+		sum.Entries = append(sum.Entries, SumEntry{
+			Name:     pkg.Name,
+			Revision: pkg.Revision,
+			Hash:     hash,
+		})
+	}
+
+	sort.Slice(sum.Entries, func(i, j int) bool {
+		return sum.Entries[i].Name < sum.Entries[j].Name
+	})
+	return sum, nil
+}
+
+/**
+ * Read sum file from dir, nil sum when file does not exist
+ *
+ * @param {string} dir
+ * @return {*Sum}
+ * @return {error}
+ */
+func ReadSum(dir string) (*Sum, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, SUMFILE))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	sum := &Sum{Entries: []SumEntry{}}
+	for i, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: malformed line", SUMFILE, i+1)
+		}
+		sum.Entries = append(sum.Entries, SumEntry{
+			Name:     fields[0],
+			Revision: fields[1],
+			Hash:     fields[2],
+		})
+	}
+	return sum, nil
+}
+
+/**
+ * Write sum file into dir
+ *
+ * @param {string} dir
+ * @return {error}
+ */
+func (s *Sum) Write(dir string) error {
+	lines := []string{}
+	for _, entry := range s.Entries {
+		lines = append(lines, entry.Name+" "+entry.Revision+" "+entry.Hash+"\n")
+	}
+	return ioutil.WriteFile(filepath.Join(dir, SUMFILE), []byte(strings.Join(lines, "")), 0644)
+}
+
+/**
+ * Check every entry of other recorded for the same package and revision
+ * has the same hash, packages at revisions not recorded yet are trusted
+ *
+ * @param {*Sum} other
+ * @return {error}
+ */
+func (s *Sum) Verify(other *Sum) error {
+	recorded := map[string]string{}
+	for _, entry := range s.Entries {
+		recorded[entry.Name+"@"+entry.Revision] = entry.Hash
+	}
+
+	mismatches := []string{}
+	for _, entry := range other.Entries {
+		if hash, ok := recorded[entry.Name+"@"+entry.Revision]; ok && hash != entry.Hash {
+			mismatches = append(mismatches, fmt.Sprintf("  %s@%s: %s recorded, %s found", entry.Name, entry.Revision, hash, entry.Hash))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("vendored files do not match %s:\n%s", SUMFILE, strings.Join(mismatches, "\n"))
+	}
+	return nil
+}
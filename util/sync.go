@@ -0,0 +1,113 @@
+package util
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+/**
+ * Make dest a copy of source, copying only files whose size, mode or
+ * modification time differ and removing what is gone from source. Paths
+ * ignore matches relative to source are left out
+ *
+ * @param {string} source
+ * @param {string} dest
+ * @param {*Ignore} ignore
+ * @return {error}
+ */
+func SyncDir(source string, dest string, ignore *Ignore) error {
+	return syncDir(source, dest, "", ignore)
+}
+
+func syncDir(source string, dest string, rel string, ignore *Ignore) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dest, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	infos, err := ioutil.ReadDir(source)
+	if err != nil {
+		return err
+	}
+
+	synced := map[string]bool{}
+	for _, info := range infos {
+		name := info.Name()
+		src, dst := filepath.Join(source, name), filepath.Join(dest, name)
+		if info.Mode()&os.ModeSymlink != 0 {
+			// follow links like copying did, broken ones are left out
+			if info, err = os.Stat(src); err != nil {
+				continue
+			}
+		}
+		if ignore.Match(path.Join(rel, name), info.IsDir()) {
+			continue
+		}
+		synced[name] = true
+
+		if info.IsDir() {
+			if fi, err := os.Lstat(dst); err == nil && !fi.IsDir() {
+				os.Remove(dst)
+			}
+			if err = syncDir(src, dst, path.Join(rel, name), ignore); err != nil {
+				return err
+			}
+		} else if err = syncFile(src, dst, info); err != nil {
+			return err
+		}
+	}
+
+	stale, err := ioutil.ReadDir(dest)
+	if err != nil {
+		return err
+	}
+	for _, info := range stale {
+		if !synced[info.Name()] {
+			if err = os.RemoveAll(filepath.Join(dest, info.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copy file unless dest already has its size, mode and modification time
+func syncFile(source string, dest string, info os.FileInfo) error {
+	if fi, err := os.Lstat(dest); err == nil {
+		if fi.Mode() == info.Mode() && fi.Size() == info.Size() && fi.ModTime().Equal(info.ModTime()) {
+			return nil
+		}
+		if err = os.RemoveAll(dest); err != nil {
+			return err
+		}
+	}
+
+	r, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Chmod(dest, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
@@ -1,11 +1,15 @@
 package util
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"gopkg.in/urfave/cli.v2"
 )
@@ -34,6 +38,63 @@ func (t *Tool) DoList(c *cli.Context) error {
 	for _, dep := range deps {
 		t.LogI("%s %s\n", dep.Name, dep.Version)
 	}
+
+	if c == nil || !c.Bool("tree") {
+		return nil
+	}
+
+	graph, err := t.Project.Graph()
+	if err != nil {
+		return err
+	}
+
+	t.LogI("Imports %s", t.Project.Name())
+	printed := map[string]bool{}
+	for _, pkg := range graph.Direct() {
+		t.printTree(graph, pkg, "", printed)
+	}
+	return nil
+}
+
+// print package and its imports once, repeated packages are not expanded
+func (t *Tool) printTree(graph *ImportGraph, pkg string, indent string, printed map[string]bool) {
+	switch {
+	case printed[pkg]:
+		fmt.Fprintf(t.Out, "%s%s ...\n", indent, pkg)
+		return
+	case graph.Dirs[pkg] == "":
+		fmt.Fprintf(t.Out, "%s%s (missing)\n", indent, pkg)
+		return
+	}
+
+	fmt.Fprintf(t.Out, "%s%s\n", indent, pkg)
+	printed[pkg] = true
+	for _, imp := range graph.Imports[pkg] {
+		if !graph.IsProject(imp) {
+			t.printTree(graph, imp, indent+"  ", printed)
+		}
+	}
+}
+
+func (t *Tool) DoWhy(c *cli.Context) error {
+	if c == nil || c.Args().Len() == 0 {
+		return errors.New("Package is undefined")
+	}
+
+	graph, err := t.Project.Graph()
+	if err != nil {
+		return err
+	}
+
+	pkg := c.Args().First()
+	chain := graph.Why(pkg)
+	if chain == nil {
+		return fmt.Errorf("%s is not imported by %s", pkg, t.Project.Name())
+	}
+
+	for i, imp := range chain {
+		fmt.Fprintf(t.Out, "%s%s\n", strings.Repeat("  ", i), imp)
+	}
 	return nil
 }
 
@@ -42,66 +103,577 @@ func (t *Tool) DoClean(c *cli.Context) error {
 	return t.Project.Clean()
 }
 
-//func (t *Tool) DoSearch(c *cli.Context) error {
-//	query := ""
-//	if c.Args().Len() > 0 {
-//		query = c.Args().First()
-//	}
-//	t.LogI("Searching %s: %s ...", t.Project.Name(), query)
-//	return nil
-//}
+func (t *Tool) DoSearch(c *cli.Context) error {
+	if c == nil || c.Args().Len() == 0 {
+		return errors.New("Query is undefined")
+	}
+	query := strings.Join(c.Args().Slice(), " ")
+
+	results, err := t.Project.Search(query)
+	if err != nil {
+		return err
+	}
+
+	limit := c.Int("limit")
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	t.LogI("Searching %s: %s (%d)", t.Project.Name(), query, len(results))
+	w := tabwriter.NewWriter(t.Out, 0, 4, 2, ' ', 0)
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\n", result.Path, result.Synopsis)
+		if len(result.Matches) > 0 {
+			fmt.Fprintf(w, "\t  %s\n", strings.Join(result.Matches, ", "))
+		}
+	}
+	return w.Flush()
+}
+
+// refresh search index from lock written after vendor dir changes, search
+// still works with stale index so failure is only reported
+func (t *Tool) index() {
+	if err := t.Project.Index(); err != nil {
+		t.LogE("  Indexing fail: %s", err.Error())
+	}
+}
 
 func (t *Tool) DoInstall(c *cli.Context) error {
 	t.LogI("Installing %s ...", t.Project.Name())
 
+	if t.Project.IsModule() {
+		t.LogI("  Downloading modules of %s", GOMODFILE)
+		return t.Project.GoRun("mod", "download")
+	}
+
+	frozen := c != nil && c.Bool("frozen")
+	keepGoing := c != nil && c.Bool("keep-going")
+	jobs := 1
+	if c != nil {
+		jobs = c.Int("jobs")
+	}
+
 	dependencies := t.Project.Dependencies()
+	lock, err := t.Project.Lock()
+	if err != nil {
+		return err
+	}
+
+	if lock != nil && lock.Matches(dependencies) {
+		t.LogI("  Restoring from %s", LOCKFILE)
+		if err = t.Project.Restore(lock); err != nil {
+			restoreErr, ok := err.(*RestoreError)
+			if !ok {
+				return err
+			}
+
+			failures := []installFailure{}
+			for i, pkg := range restoreErr.Packages {
+				failures = append(failures, installFailure{pkg.Name, pkg.Revision, restoreErr.Errors[i]})
+			}
+			t.reportFailures(err, failures)
+			if !keepGoing {
+				return err
+			}
+		}
+	} else if frozen {
+		if lock == nil {
+			return errors.New(LOCKFILE + " not found")
+		}
+		return errors.New("Dependencies do not match " + LOCKFILE)
+	} else {
+		t.LogI("  Getting %d dependencies", len(dependencies))
+		failures := []installFailure{}
+		finished := 0
+		RunPool(jobs, len(dependencies), func(i int) error {
+			return t.Project.Get(dependencies[i])
+		}, func(i int, err error) {
+			finished++
+			dep := dependencies[i]
+			if err != nil {
+				failures = append(failures, installFailure{dep.Name, dep.Version, err})
+				t.LogE("  [%d/%d] ---> %s@%s fail", finished, len(dependencies), dep.Name, dep.Version)
+				return
+			}
+			t.LogI("  [%d/%d] Got %s@%s", finished, len(dependencies), dep.Name, dep.Version)
+		})
+
+		if len(failures) > 0 {
+			err = fmt.Errorf("%d of %d dependencies failed", len(failures), len(dependencies))
+			t.reportFailures(err, failures)
+			if !keepGoing {
+				return err
+			}
+		}
+	}
+
+	t.LogI("  Verifying %s", SUMFILE)
+	if err = t.Project.Verify(); err != nil {
+		return err
+	}
+
+	graph, err := t.Project.Graph()
+	if err != nil {
+		return err
+	}
+
+	packages := []string{}
+	for _, pkg := range graph.Reachable() {
+		if graph.Dirs[pkg] != "" {
+			packages = append(packages, pkg)
+		}
+	}
+
+	failures := []installFailure{}
+	finished := 0
+	RunPool(jobs, len(packages), func(i int) error {
+		return t.Project.GoRun("install", packages[i])
+	}, func(i int, err error) {
+		finished++
+		if err != nil {
+			failures = append(failures, installFailure{packages[i], "", err})
+			t.LogE("  [%d/%d] ---> %s fail", finished, len(packages), packages[i])
+			return
+		}
+		t.LogI("  [%d/%d] Installed %s", finished, len(packages), packages[i])
+	})
+	if len(failures) > 0 {
+		err = fmt.Errorf("%d of %d packages failed to install", len(failures), len(packages))
+		t.reportFailures(err, failures)
+		return err
+	}
+
+	if !frozen {
+		if err = t.Project.WriteLock(); err != nil {
+			return err
+		}
+	}
+	t.index()
+	return nil
+}
+
+type installFailure struct {
+	name    string
+	version string
+	err     error
+}
+
+// print table of failures with first line of each error, followed by full
+// output of errors spanning more lines
+func (t *Tool) reportFailures(summary error, failures []installFailure) {
+	t.LogE("  %s", summary.Error())
+
+	w := tabwriter.NewWriter(t.Err, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DEPENDENCY\tVERSION\tERROR")
+	for _, f := range failures {
+		version := f.version
+		if version == "" {
+			version = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.name, version, strings.SplitN(f.err.Error(), "\n", 2)[0])
+	}
+	w.Flush()
+
+	for _, f := range failures {
+		lines := strings.Split(strings.TrimSpace(f.err.Error()), "\n")
+		if len(lines) < 2 {
+			continue
+		}
+		fmt.Fprintf(t.Err, "\n%s:\n", f.name)
+		for _, line := range lines {
+			fmt.Fprintf(t.Err, "    %s\n", line)
+		}
+	}
+}
+
+func (t *Tool) DoUpdate(c *cli.Context) error {
+	var (
+		before *Lock
+		after  *Lock
+		err    error
+	)
+
+	names := []string{}
+	if c != nil {
+		names = c.Args().Slice()
+	}
+
+	dependencies := []Dependency{}
+	for _, dep := range t.Project.Dependencies() {
+		if len(names) == 0 {
+			dependencies = append(dependencies, dep)
+			continue
+		}
+		for _, name := range names {
+			if dep.Name == name {
+				dependencies = append(dependencies, dep)
+			}
+		}
+	}
+
+	if len(names) > 0 && len(dependencies) != len(names) {
+		return fmt.Errorf("Not all of %s are dependencies of %s", strings.Join(names, ", "), t.Project.Name())
+	}
+
+	t.LogI("Updating %s ...", t.Project.Name())
+
+	if before, err = t.Project.Scan(); err != nil {
+		return err
+	}
+
 	for _, dep := range dependencies {
-		t.LogI("  Getting %s@%s", dep.Name, dep.Version)
-		if err := t.Project.Get(dep); err != nil {
-			t.LogE("  ---> %s@%s fail", dep.Name, dep.Version)
-		}
-	}
-
-	baseDir := "_vendor/src"
-	err := filepath.Walk(baseDir, func(path string, fi os.FileInfo, err error) error {
-		if fi != nil && fi.IsDir() && path != baseDir {
-			if fi.Name() == ".git" {
-				return filepath.SkipDir
-			} else {
-				//fmt.Println("{{", path)
-				err := filepath.Walk(path, func(childPath string, fi os.FileInfo, err error) error {
-					if fi.IsDir() {
-						if path == childPath {
-							return nil
-						} else {
-							return filepath.SkipDir
-						}
-						//} else if fi.Name() == ".git" {
-						//	return filepath.SkipDir
-						//}
-					}
-
-					//fmt.Println("  --", childPath, err)
-					if filepath.Ext(childPath) == ".go" {
-						//fmt.Println("  >>", childPath, filepath.Ext(childPath))
-						return PackageFound
-					}
-					return nil
-				})
-				//fmt.Println("}}", err)
-				if err == PackageFound {
-					pkg := path[len(baseDir)+1:]
-					t.LogI("  Installing %s", pkg)
-					if err = t.Project.GoRun("install", pkg); err != nil {
-						return err
-					}
-					return filepath.SkipDir
-				}
+		t.LogI("  Updating %s@%s", dep.Name, dep.Version)
+		version, err := t.Project.Update(dep)
+		if err != nil {
+			return err
+		}
+		if version == "" {
+			continue
+		}
+
+		t.LogI("  Recording %s@%s in %s", dep.Name, version, GOPASYML)
+		if err = t.Project.SetDependencyVersion(dep.Name, version); err != nil {
+			return err
+		}
+	}
+
+	if after, err = t.Project.Scan(); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(t.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DEPENDENCY\tBEFORE\tAFTER")
+	for _, dep := range dependencies {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", dep.Name, lockedRevision(before, dep.Name), lockedRevision(after, dep.Name))
+	}
+	w.Flush()
+
+	if err = t.Project.WriteLock(); err != nil {
+		return err
+	}
+	t.index()
+	return nil
+}
+
+func lockedRevision(lock *Lock, name string) string {
+	if pkg := lock.Package(name); pkg != nil {
+		return ShortRevision(pkg.Revision)
+	}
+	return "-"
+}
+
+func (t *Tool) DoOutdated(c *cli.Context) error {
+	reports := []*Outdated{}
+	for _, dep := range t.Project.Dependencies() {
+		report, err := t.Project.Outdated(dep)
+		if err != nil {
+			// one broken checkout must not hide the rest, report it unknown
+			t.LogE("  %s: %s", dep.Name, err.Error())
+			report = &Outdated{Name: dep.Name, Version: dep.Version}
+		}
+		reports = append(reports, report)
+	}
+
+	if c != nil && c.Bool("json") {
+		encoder := json.NewEncoder(t.Out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	}
+
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+
+	w := tabwriter.NewWriter(t.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DEPENDENCY\tVERSION\tCURRENT\tWANTED\tLATEST\t")
+	for _, report := range reports {
+		mark := ""
+		if report.Outdated {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", report.Name, orDash(report.Version),
+			orDash(report.Current), orDash(report.Wanted), orDash(report.Latest), mark)
+	}
+	return w.Flush()
+}
+
+func (t *Tool) DoAdd(c *cli.Context) error {
+	if c == nil || c.Args().Len() == 0 {
+		return errors.New("Package is undefined")
+	}
+
+	for _, arg := range c.Args().Slice() {
+		dependency := ParseDependency(arg)
+		if dependency.Name == "" || strings.ContainsAny(dependency.Name, " \t\r\n") {
+			return fmt.Errorf("Invalid package name %q", dependency.Name)
+		}
+		for _, dep := range t.Project.Dependencies() {
+			if dep.Name == dependency.Name {
+				return fmt.Errorf("%s is already a dependency", dep.Name)
 			}
 		}
+
+		t.LogI("Adding %s ...", dependency)
+		if err := t.Project.Get(dependency); err != nil {
+			return err
+		}
+		if err := t.Project.AddDependency(dependency); err != nil {
+			return err
+		}
+	}
+
+	if err := t.Project.WriteLock(); err != nil {
+		return err
+	}
+	t.index()
+	return nil
+}
+
+func (t *Tool) DoRemove(c *cli.Context) error {
+	if c == nil || c.Args().Len() == 0 {
+		return errors.New("Package is undefined")
+	}
+
+	for _, name := range c.Args().Slice() {
+		t.LogI("Removing %s ...", name)
+		if err := t.Project.RemoveDependency(name); err != nil {
+			return err
+		}
+	}
+
+	return t.Project.WriteLock()
+}
+
+func (t *Tool) DoImport(c *cli.Context) error {
+	source := ""
+	if c != nil {
+		source = c.Args().First()
+	}
+
+	manifest, source, err := t.Project.ImportManifest(source)
+	if err != nil {
+		return err
+	}
+
+	t.LogI("Importing %d dependencies from %s ...", len(manifest.Dependencies), source)
+	for _, dep := range manifest.Dependencies {
+		t.LogI("  %s", dep)
+	}
+
+	return t.Project.WriteManifest(manifest, c != nil && c.Bool("force"))
+}
+
+func (t *Tool) DoExport(c *cli.Context) error {
+	if c == nil || c.Args().Len() == 0 {
+		return errors.New("Format is undefined")
+	}
+	if format := c.Args().First(); format != "gomod" {
+		return fmt.Errorf("Unknown format %s, expected gomod", format)
+	}
+
+	t.LogI("Exporting %s to %s ...", t.Project.Name(), GOMODFILE)
+	return t.Project.ExportGoMod(c.Bool("force"))
+}
+
+func (t *Tool) DoLicenses(c *cli.Context) error {
+	licenses, err := t.Project.Licenses()
+	if err != nil {
+		return err
+	}
+	policy := t.Project.LicensePolicy()
+
+	if c != nil && c.Bool("csv") {
+		w := csv.NewWriter(t.Out)
+		w.Write([]string{"package", "revision", "license", "file", "allowed"})
+		for _, license := range licenses {
+			w.Write([]string{license.Name, license.Revision, license.Type, license.File, strconv.FormatBool(policy.Allows(license.Type))})
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	w := tabwriter.NewWriter(t.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tREVISION\tLICENSE\tFILE\t")
+	for _, license := range licenses {
+		file, mark := license.File, ""
+		if file == "" {
+			file = "-"
+		}
+		if !policy.Allows(license.Type) {
+			mark = "denied"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", license.Name, ShortRevision(license.Revision), license.Type, file, mark)
+	}
+	return w.Flush()
+}
+
+func (t *Tool) DoAudit(c *cli.Context) error {
+	db, failOn := "", "low"
+	if c != nil {
+		db, failOn = c.String("db"), c.String("fail-on")
+	}
+	threshold, err := ParseSeverity(failOn)
+	if err != nil {
+		return err
+	}
+
+	t.LogI("Auditing %s ...", t.Project.Name())
+	findings, unversioned, err := t.Project.Audit(db)
+	if err != nil {
+		return err
+	}
+
+	if len(unversioned) > 0 {
+		t.LogI("  No version known for %d packages, only checked against git ranges: %s", len(unversioned), strings.Join(unversioned, ", "))
+	}
+	if len(findings) == 0 {
+		t.LogI("  No advisories found")
 		return nil
-	})
-	return err
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(t.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tVERSION\tADVISORY\tSEVERITY\tFIXED\tSUMMARY")
+	for _, finding := range findings {
+		version, fixed := finding.Version, strings.Join(finding.Fixed, ", ")
+		if version == "" {
+			version = ShortRevision(finding.Revision)
+		}
+		if fixed == "" {
+			fixed = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", finding.Name, version, finding.ID, finding.Severity, fixed, finding.Summary)
+
+		if SeverityLevel(finding.Severity) >= threshold {
+			failed++
+		}
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d advisories at or above %s severity", failed, failOn)
+	}
+	return nil
+}
+
+// fail when vendored package has license policy of gopas.yml does not allow
+func (t *Tool) checkLicenses() error {
+	policy := t.Project.LicensePolicy()
+	if policy.IsEmpty() {
+		return nil
+	}
+	if t.Project.IsModule() {
+		t.LogE("  Licenses of modules are not checked")
+		return nil
+	}
+
+	t.LogI("Checking licenses of %s ...", t.Project.Name())
+	licenses, err := t.Project.Licenses()
+	if err != nil {
+		return err
+	}
+
+	denied := []string{}
+	for _, license := range licenses {
+		if !policy.Allows(license.Type) {
+			denied = append(denied, fmt.Sprintf("  %s: %s", license.Name, license.Type))
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("Licenses not allowed by %s:\n%s", GOPASYML, strings.Join(denied, "\n"))
+	}
+	return nil
+}
+
+func (t *Tool) DoDepsCheck(c *cli.Context) error {
+	// dependency used only by tests is still a dependency
+	graph, err := t.Project.FullGraph()
+	if err != nil {
+		return err
+	}
+
+	report := t.Project.CheckDependencies(graph)
+	for _, dep := range report.Unused {
+		t.LogI("Unused %s", dep.Name)
+	}
+	for _, pkg := range report.Undeclared {
+		t.LogI("Undeclared %s", pkg)
+	}
+	for _, pkg := range report.Missing {
+		t.LogE("Missing %s", pkg)
+	}
+
+	if report.IsClean() {
+		t.LogI("Dependencies of %s are clean", t.Project.Name())
+		return nil
+	}
+
+	if c == nil || !c.Bool("fix") {
+		return fmt.Errorf("Dependencies of %s do not match imports", t.Project.Name())
+	}
+
+	for _, dep := range report.Unused {
+		t.LogI("Removing %s ...", dep.Name)
+		if err = t.Project.RemoveDependency(dep.Name); err != nil {
+			return err
+		}
+	}
+	for _, pkg := range report.Undeclared {
+		t.LogI("Adding %s ...", pkg)
+		if err = t.Project.AddDependency(Dependency{Name: pkg}); err != nil {
+			return err
+		}
+	}
+
+	if len(report.Missing) > 0 {
+		return fmt.Errorf("Missing packages can not be fixed, try gopas add")
+	}
+	return t.Project.WriteLock()
+}
+
+func (t *Tool) DoVendorPrune(c *cli.Context) error {
+	dryRun := c != nil && c.Bool("dry-run")
+
+	t.LogI("Pruning vendored packages of %s ...", t.Project.Name())
+	pruned, err := t.Project.Prune(dryRun)
+	if err != nil {
+		return err
+	}
+
+	files, bytes := 0, int64(0)
+	for _, pkg := range pruned {
+		t.LogI("  %s: %d files, %s", pkg.Name, len(pkg.Files), formatBytes(pkg.Bytes))
+		if dryRun {
+			for _, file := range pkg.Files {
+				fmt.Fprintf(t.Out, "    %s\n", file)
+			}
+		}
+		files += len(pkg.Files)
+		bytes += pkg.Bytes
+	}
+
+	if dryRun {
+		t.LogI("Would remove %d files, saving %s", files, formatBytes(bytes))
+	} else {
+		t.LogI("Removed %d files, saved %s", files, formatBytes(bytes))
+	}
+	return nil
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value, prefix := float64(bytes)/unit, 0
+	for value >= unit && prefix < 3 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[prefix])
 }
 
 func (t *Tool) DoRun(c *cli.Context) error {
@@ -122,6 +694,10 @@ func (t *Tool) DoBuild(c *cli.Context) error {
 		return err
 	}
 
+	if err := t.checkLicenses(); err != nil {
+		return err
+	}
+
 	t.LogI("Pre Building %s ...\n", t.Project.Name())
 	if err := t.Project.PreBuild(); err != nil {
 		return err
@@ -134,17 +710,19 @@ func (t *Tool) DoBuild(c *cli.Context) error {
 func (t *Tool) DoTest(c *cli.Context) error {
 	t.LogI("Testing %s ...\n", t.Project.Name())
 	cover := c.Bool("cover")
-	if cover {
-		cwd, _ := os.Getwd()
-		t.LogI(
-			"Coverage html: %s",
-			filepath.Join(cwd, ".gopath", "src", t.Project.Name(), "cover.html"))
-	}
 	args := []string{}
 	if c != nil {
 		args = c.Args().Slice()
 	}
-	return t.Project.Test(cover, args...)
+	if err := t.Project.Test(cover, args...); err != nil {
+		return err
+	}
+
+	// project dir is known once test bootstrapped project
+	if cover {
+		t.LogI("Coverage html: %s", filepath.Join(t.Project.Dir(), "cover.html"))
+	}
+	return nil
 }
 
 func (t *Tool) DoWatch(c *cli.Context) error {
@@ -153,12 +731,18 @@ func (t *Tool) DoWatch(c *cli.Context) error {
 		exeArgs []string
 	)
 
+	ignore, err := t.Project.Ignore()
+	if err != nil {
+		return err
+	}
+
 	t.LogI("Watching %s ...\n", t.Project.Name())
 	watcher := &Watcher{
 		Logger:     t.Logger,
 		Watches:    c.StringSlice("watch"),
 		Extensions: strings.Split(c.String("ext"), ","),
 		Ignores:    c.StringSlice("ignore"),
+		Ignore:     ignore,
 	}
 
 	exec := c.String("exec")
@@ -0,0 +1,543 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * Vcs type describes how to drive a version control tool, commands are
+ * argument templates where {ref}, {rev}, {repo} and {dir} are substituted
+ * before running
+ */
+type Vcs struct {
+	Name        string
+	Cmd         string
+	Meta        string
+	CreateCmd   []string
+	DownloadCmd []string
+	CheckoutCmd []string
+	RevisionCmd []string
+	TimeCmd     []string
+	RemoteCmd   []string
+	TagsCmd     []string
+	TagCmd      []string
+	ResolveCmd  []string
+	AncestorCmd []string
+	RevertCmd   []string
+	UpstreamRef string
+	DefaultRef  string
+
+	MirrorCmd    []string
+	SyncCmd      []string
+	SetRemoteCmd []string
+}
+
+var (
+	VcsGit = &Vcs{
+		Name:        "git",
+		Cmd:         "git",
+		Meta:        ".git",
+		CreateCmd:   []string{"clone", "-q", "{repo}", "{dir}"},
+		DownloadCmd: []string{"fetch", "--tags", "origin"},
+		CheckoutCmd: []string{"checkout", "-q", "{ref}"},
+		RevisionCmd: []string{"rev-parse", "HEAD"},
+		TimeCmd:     []string{"show", "-s", "--format=%ct", "HEAD"},
+		RemoteCmd:   []string{"config", "--get", "remote.origin.url"},
+		TagsCmd:     []string{"tag", "-l"},
+		TagCmd:      []string{"describe", "--tags", "--exact-match", "HEAD"},
+		ResolveCmd:  []string{"rev-parse", "--verify", "-q", "{ref}^{commit}"},
+		AncestorCmd: []string{"merge-base", "--is-ancestor", "{ref}", "HEAD"},
+		RevertCmd:   []string{"checkout", "-q", "--", "."},
+		UpstreamRef: "origin/{ref}",
+		DefaultRef:  "origin/HEAD",
+
+		MirrorCmd:    []string{"clone", "-q", "--mirror", "{repo}", "{dir}"},
+		SyncCmd:      []string{"fetch", "-q", "--tags", "{repo}", "+refs/heads/*:refs/remotes/origin/*"},
+		SetRemoteCmd: []string{"remote", "set-url", "origin", "{repo}"},
+	}
+
+	VcsHg = &Vcs{
+		Name:        "hg",
+		Cmd:         "hg",
+		Meta:        ".hg",
+		CreateCmd:   []string{"clone", "-q", "{repo}", "{dir}"},
+		DownloadCmd: []string{"pull", "-q"},
+		CheckoutCmd: []string{"update", "-q", "-r", "{ref}"},
+		RevisionCmd: []string{"log", "-r", ".", "--template", "{node}"},
+		TimeCmd:     []string{"log", "-r", ".", "--template", "{date|hgdate}"},
+		RemoteCmd:   []string{"paths", "default"},
+		TagsCmd:     []string{"tags", "-q"},
+		TagCmd:      []string{"log", "-r", ".", "--template", "{tags}"},
+		ResolveCmd:  []string{"log", "-r", "{ref}", "--template", "{node}"},
+		RevertCmd:   []string{"revert", "-q", "--all", "--no-backup"},
+		UpstreamRef: "{ref}",
+		DefaultRef:  "default",
+	}
+
+	VcsBzr = &Vcs{
+		Name:        "bzr",
+		Cmd:         "bzr",
+		Meta:        ".bzr",
+		CreateCmd:   []string{"branch", "-q", "{repo}", "{dir}"},
+		DownloadCmd: []string{"pull", "-q", "--overwrite"},
+		CheckoutCmd: []string{"update", "-q", "-r", "{ref}"},
+		RevisionCmd: []string{"version-info", "--custom", "--template={revision_id}"},
+		RemoteCmd:   []string{"config", "parent_location"},
+		TagsCmd:     []string{"tags"},
+		TagCmd:      []string{"tags", "-r", "revid:{rev}"},
+		ResolveCmd:  []string{"version-info", "--custom", "--template={revision_id}", "-r", "{ref}"},
+		RevertCmd:   []string{"revert", "-q", "--no-backup"},
+		UpstreamRef: "{ref}",
+		DefaultRef:  "-1",
+	}
+
+	// subversion keeps no local history, tags are directories so versions
+	// are revision numbers and downloading happens on checkout
+	VcsSvn = &Vcs{
+		Name:        "svn",
+		Cmd:         "svn",
+		Meta:        ".svn",
+		CreateCmd:   []string{"checkout", "-q", "{repo}", "{dir}"},
+		DownloadCmd: []string{"info", "-r", "HEAD"},
+		CheckoutCmd: []string{"update", "-q", "-r", "{ref}"},
+		RevisionCmd: []string{"info", "--show-item", "revision"},
+		RemoteCmd:   []string{"info", "--show-item", "url"},
+		ResolveCmd:  []string{"info", "--show-item", "revision", "-r", "{ref}"},
+		RevertCmd:   []string{"revert", "-q", "-R", "."},
+		UpstreamRef: "{ref}",
+		DefaultRef:  "HEAD",
+	}
+
+	vcsList = []*Vcs{
+		VcsGit,
+		VcsHg,
+		VcsBzr,
+		VcsSvn,
+	}
+)
+
+/**
+ * Find vcs used by repository at dir
+ *
+ * @param {string} dir
+ * @return {*Vcs}
+ */
+func VcsForDir(dir string) *Vcs {
+	for _, vcs := range vcsList {
+		if _, err := os.Stat(filepath.Join(dir, vcs.Meta)); err == nil {
+			return vcs
+		}
+	}
+	return nil
+}
+
+/**
+ * Find vcs by its name
+ *
+ * @param {string} name
+ * @return {*Vcs}
+ */
+func VcsByName(name string) *Vcs {
+	for _, vcs := range vcsList {
+		if vcs.Name == name {
+			return vcs
+		}
+	}
+	return nil
+}
+
+// hosts serving repositories of one vcs only
+var vcsHosts = map[string]*Vcs{
+	"github.com":          VcsGit,
+	"gitlab.com":          VcsGit,
+	"bitbucket.org":       VcsGit,
+	"go.googlesource.com": VcsGit,
+	"launchpad.net":       VcsBzr,
+	"code.launchpad.net":  VcsBzr,
+}
+
+/**
+ * Guess vcs of remote url from its scheme, user, host or path, nil when
+ * nothing tells
+ *
+ * @param {string} remote
+ * @return {*Vcs}
+ */
+func VcsForRemote(remote string) *Vcs {
+	scheme := ""
+	if i := strings.Index(remote, "://"); i > 0 {
+		scheme, remote = remote[:i], remote[i+3:]
+	} else if strings.HasPrefix(remote, "lp:") {
+		return VcsBzr
+	}
+
+	// scheme of vcs own protocol, e.g. git:// or bzr+ssh://
+	for _, vcs := range vcsList {
+		if scheme == vcs.Name || strings.HasPrefix(scheme, vcs.Name+"+") {
+			return vcs
+		}
+	}
+
+	host, path := remote, ""
+	if scheme == "file" {
+		host, path = "", remote
+	} else if i := strings.IndexAny(remote, "/:"); i >= 0 {
+		host, path = remote[:i], remote[i+1:]
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		// e.g. git@github.com or hg@bitbucket.org
+		if vcs := VcsByName(host[:i]); vcs != nil {
+			return vcs
+		}
+		host = host[i+1:]
+	}
+	if vcs := vcsHosts[host]; vcs != nil {
+		return vcs
+	}
+
+	path = strings.TrimSuffix(path, "/")
+	for _, vcs := range vcsList {
+		// e.g. repo.git, hg.example.com or example.com/svn/repo
+		if strings.HasSuffix(path, "."+vcs.Name) || strings.HasPrefix(host, vcs.Name+".") ||
+			strings.HasPrefix(path, vcs.Name+"/") || strings.Contains(path, "/"+vcs.Name+"/") {
+			return vcs
+		}
+	}
+	return nil
+}
+
+/**
+ * Walk up from dir until repository root found, never leaving base
+ *
+ * @param {string} base
+ * @param {string} dir
+ * @return {string}
+ * @return {*Vcs}
+ * @return {error}
+ */
+func FindRepoRoot(base string, dir string) (string, *Vcs, error) {
+	base = filepath.Clean(base)
+	for dir = filepath.Clean(dir); strings.HasPrefix(dir, base+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if vcs := VcsForDir(dir); vcs != nil {
+			return dir, vcs, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no repository found for %s", dir)
+}
+
+func (v *Vcs) run(dir string, template []string, keyval ...string) (string, error) {
+	args := make([]string, len(template))
+	for i, arg := range template {
+		for j := 0; j+1 < len(keyval); j += 2 {
+			arg = strings.Replace(arg, "{"+keyval[j]+"}", keyval[j+1], -1)
+		}
+		args[i] = arg
+	}
+
+	runner := &Runner{
+		Name: v.Cmd,
+		Args: args,
+		Dir:  dir,
+	}
+	out, err := runner.Output()
+	return strings.TrimSpace(out), err
+}
+
+/**
+ * Checkout ref (tag, branch or commit), download from remote when ref is
+ * not known locally yet
+ *
+ * @param {string} dir
+ * @param {string} ref
+ * @return {error}
+ */
+func (v *Vcs) Checkout(dir string, ref string) error {
+	if ref == "" {
+		return errors.New("Ref is undefined")
+	}
+
+	if err := v.CheckoutLocal(dir, ref); err == nil {
+		return nil
+	}
+
+	if _, err := v.run(dir, v.DownloadCmd); err != nil {
+		return err
+	}
+
+	if _, err := v.run(dir, v.CheckoutCmd, "ref", ref); err != nil {
+		return fmt.Errorf("revision %s not found: %s", ref, err.Error())
+	}
+	return nil
+}
+
+/**
+ * Checkout ref already downloaded, never touching remote
+ *
+ * @param {string} dir
+ * @param {string} ref
+ * @return {error}
+ */
+func (v *Vcs) CheckoutLocal(dir string, ref string) error {
+	if ref == "" {
+		return errors.New("Ref is undefined")
+	}
+
+	if err := v.unprune(dir, ref); err != nil {
+		return err
+	}
+
+	_, err := v.run(dir, v.CheckoutCmd, "ref", ref)
+	return err
+}
+
+/**
+ * Download new revisions from remote
+ *
+ * @param {string} dir
+ * @return {error}
+ */
+func (v *Vcs) Download(dir string) error {
+	_, err := v.run(dir, v.DownloadCmd)
+	return err
+}
+
+/**
+ * Checkout newest downloaded revision of branch ref, or of default branch
+ * when ref is empty. Ref that is not a branch is checked out as is
+ *
+ * @param {string} dir
+ * @param {string} ref
+ * @return {error}
+ */
+func (v *Vcs) CheckoutUpstream(dir string, ref string) error {
+	return v.checkoutUpstream(dir, ref, v.Checkout)
+}
+
+// checkout upstream of ref, falling back to checkout of ref itself
+func (v *Vcs) checkoutUpstream(dir string, ref string, checkout func(dir string, ref string) error) error {
+	if ref == "" {
+		return checkout(dir, v.DefaultRef)
+	}
+
+	upstream := strings.Replace(v.UpstreamRef, "{ref}", ref, -1)
+	if err := v.CheckoutLocal(dir, upstream); err == nil {
+		return nil
+	}
+	return checkout(dir, ref)
+}
+
+// bring back files removed by prune unless ref is the pruned revision,
+// checkout alone leaves files unchanged between revisions removed
+func (v *Vcs) unprune(dir string, ref string) error {
+	marker := ReadPruneMarker(dir)
+	if marker == nil {
+		return nil
+	}
+	if rev, err := v.run(dir, v.ResolveCmd, "ref", ref); err == nil && rev == marker.Revision {
+		return nil
+	}
+
+	if _, err := v.run(dir, v.RevertCmd); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(dir, PRUNEMETA))
+}
+
+/**
+ * Get current revision of repository
+ *
+ * @param {string} dir
+ * @return {string}
+ * @return {error}
+ */
+func (v *Vcs) Revision(dir string) (string, error) {
+	return v.run(dir, v.RevisionCmd)
+}
+
+/**
+ * Get commit time of current revision
+ *
+ * @param {string} dir
+ * @return {time.Time}
+ * @return {error}
+ */
+func (v *Vcs) Time(dir string) (time.Time, error) {
+	if v.TimeCmd == nil {
+		return time.Time{}, fmt.Errorf("commit time not supported by %s", v.Name)
+	}
+
+	out, err := v.run(dir, v.TimeCmd)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// hg prints timezone offset after seconds
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return time.Time{}, errors.New("commit time not found")
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+/**
+ * Get remote url repository at dir was created from
+ *
+ * @param {string} dir
+ * @return {string}
+ * @return {error}
+ */
+func (v *Vcs) Remote(dir string) (string, error) {
+	return v.run(dir, v.RemoteCmd)
+}
+
+/**
+ * List tags of repository
+ *
+ * @param {string} dir
+ * @return {[]string}
+ * @return {error}
+ */
+func (v *Vcs) Tags(dir string) ([]string, error) {
+	if v.TagsCmd == nil {
+		return []string{}, nil
+	}
+
+	out, err := v.run(dir, v.TagsCmd)
+	if err != nil || out == "" {
+		return []string{}, err
+	}
+
+	// bzr prints revision next to each tag, hg lists tip as a tag
+	tags := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] != "tip" {
+			tags = append(tags, fields[0])
+		}
+	}
+	return tags, nil
+}
+
+/**
+ * Select version of repository at dir satisfying requirements from its
+ * tags, vcs listing no tags supports exact revisions only
+ *
+ * @param {string} dir
+ * @param {string} name
+ * @param {[]Requirement} requirements
+ * @return {string}
+ * @return {error}
+ */
+func (v *Vcs) SelectVersion(dir string, name string, requirements []Requirement) (string, error) {
+	if v.TagsCmd == nil {
+		for _, req := range requirements {
+			if IsConstraint(req.Version) {
+				return "", fmt.Errorf("version constraints not supported for %s", v.Name)
+			}
+		}
+	}
+
+	tags, err := v.Tags(dir)
+	if err != nil {
+		return "", err
+	}
+	return SelectVersion(name, tags, requirements)
+}
+
+/**
+ * Get tag pointing at current revision, empty when there is none
+ *
+ * @param {string} dir
+ * @return {string}
+ */
+func (v *Vcs) Tag(dir string) string {
+	if v.TagCmd == nil {
+		return ""
+	}
+
+	// bzr lists tags of revision given, not of working tree
+	rev, err := v.Revision(dir)
+	if err != nil {
+		return ""
+	}
+
+	out, err := v.run(dir, v.TagCmd, "rev", rev)
+	if err != nil {
+		return ""
+	}
+
+	for _, tag := range strings.Fields(out) {
+		if tag != "tip" {
+			return tag
+		}
+	}
+	return ""
+}
+
+/**
+ * Resolve ref into revision without checking it out, branch ref resolves
+ * to its newest downloaded revision
+ *
+ * @param {string} dir
+ * @param {string} ref
+ * @return {string}
+ * @return {error}
+ */
+func (v *Vcs) Resolve(dir string, ref string) (string, error) {
+	if ref == "" {
+		return v.run(dir, v.ResolveCmd, "ref", v.DefaultRef)
+	}
+
+	upstream := strings.Replace(v.UpstreamRef, "{ref}", ref, -1)
+	if rev, err := v.run(dir, v.ResolveCmd, "ref", upstream); err == nil {
+		return rev, nil
+	}
+	return v.run(dir, v.ResolveCmd, "ref", ref)
+}
+
+/**
+ * Tell whether ref is current revision or one of its ancestors, false when
+ * ref is unknown or vcs can not tell
+ *
+ * @param {string} dir
+ * @param {string} ref
+ * @return {bool}
+ */
+func (v *Vcs) IsAncestor(dir string, ref string) bool {
+	if v.AncestorCmd == nil {
+		return false
+	}
+	_, err := v.run(dir, v.AncestorCmd, "ref", ref)
+	return err == nil
+}
+
+/**
+ * Create repository at dir from remote repo
+ *
+ * @param {string} repo
+ * @param {string} dir
+ * @return {error}
+ */
+func (v *Vcs) Create(repo string, dir string) error {
+	var err error
+
+	if dir, err = filepath.Abs(dir); err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+
+	_, err = v.run(filepath.Dir(dir), v.CreateCmd, "repo", repo, "dir", dir)
+	return err
+}
@@ -14,6 +14,7 @@ type Watcher struct {
 	Watches      []string
 	Extensions   []string
 	Ignores      []string
+	Ignore       *Ignore
 	cb           func() (*Runner, error)
 	runner       *Runner
 	modifiedTime time.Time
@@ -36,8 +37,14 @@ func (w *Watcher) Watch(cb func() (*Runner, error)) error {
 		for {
 			for _, dir := range w.Watches {
 				filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-					if w.isIgnorable(path) {
-						return filepath.SkipDir
+					if err != nil {
+						return nil
+					}
+					if w.isIgnorable(path, info.IsDir()) {
+						if info.IsDir() {
+							return filepath.SkipDir
+						}
+						return nil
 					}
 
 					if w.isAcceptable(path) && info.ModTime().After(w.modifiedTime) {
@@ -97,11 +104,17 @@ func (w *Watcher) Start() error {
 }
 
 /**
- * Check whether path is ignorable
+ * Check whether path is ignorable, by ignore of project or by one of glob
+ * patterns
  *
  * @param {string} path
+ * @param {bool} isDir
  */
-func (w *Watcher) isIgnorable(path string) bool {
+func (w *Watcher) isIgnorable(path string, isDir bool) bool {
+	if w.Ignore != nil && w.Ignore.MatchPath(path, isDir) {
+		return true
+	}
+
 	ignorable := false
 	for _, ignore := range w.Ignores {
 		if matched, _ := filepath.Match(ignore, path); matched {